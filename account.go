@@ -1,113 +1,119 @@
 /*
-	Account resents ownership and security. An Account also handles the creating and deleting of
+	Account resents ownership and security. An Account holds the credentials of its
 	Containers. It is modeled after an Azure Storage Account. 
 
 	References: https://docs.microsoft.com/en-us/azure/storage/blobs/storage-blobs-introduction
 */
-package main
+package pit
 import (
-	"context"
 	"errors"
 	"fmt"
-	"log"
-	"net/url"
 	"os"
 	"strings"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
 )
 
-func handleAzureErrors(err error) {
-	if err != nil {
-		if serr, ok := err.(azblob.StorageError); ok { // This error is a Service-specific
-			log.Println(serr.ServiceCode())
-			switch serr.ServiceCode() { // Compare serviceCode to ServiceCodeXxx constants
-			case azblob.ServiceCodeContainerAlreadyExists:
-				log.Println("Verified container exists")
-				return
-			case "ContainerNotFound":
-				log.Println("Container not found")
-			}
-		}
-		log.Fatal(err)
-	}
-}
+// The well known account and key of the Azurite storage emulator. Reference:
+// https://docs.microsoft.com/en-us/azure/storage/common/storage-use-azurite#connection-strings
+const azuriteAccountName = "devstoreaccount1"
+const azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+const azuriteBlobEndpoint = "http://127.0.0.1:10000/" + azuriteAccountName
 
-func getAccountNameAndKey() (string, string, error) {
-	// From the Azure portal, get storage account name and key and set environment variables.
-	//     export AZURE_STORAGE_ACCOUNT="pithub"
-	//     export AZURE_STORAGE_ACCESS_KEY="2q62fVoYfT6ZOudTALzXBSz6eKOXh4CRgpMfuMWpyRFlUh/QB+K3IpaAm/hAUjrbMoZN9t0Cbl4lYHMU3lV89A=="
+// Azure storage account name, key, and blob service endpoint.
+type azureAccount struct {
+	Name     string
+	Key      string
+	Endpoint string // Example: "http://127.0.0.1:10000/devstoreaccount1". Empty for the public Azure cloud.
+}
 
-	accountName, accountKey := os.Getenv("AZURE_STORAGE_ACCOUNT"), os.Getenv("AZURE_STORAGE_ACCESS_KEY")
-	if len(accountName) == 0 || len(accountKey) == 0 {
-		account := new(accountProperties)
-		container, err := account.defaultContainer()
-		if err != nil {
-			return accountName, accountKey, err
-		}
-		
-		accountName = container.Account
-		accountKey  = container.Key
-	}
-	
-	if len(accountName) == 0 || len(accountKey) == 0 {
-		err := errors.New("Either the AZURE_STORAGE_ACCOUNT or AZURE_STORAGE_ACCESS_KEY environment variable is not set")
-		return accountName, accountKey, err
+// Returns the blob service URL of the account. All Azure URLs are derived from this URL.
+func (aa azureAccount) serviceURL() string {
+	if len(aa.Endpoint) != 0 {
+		return strings.TrimRight(aa.Endpoint, "/")
 	}
-	
-	return accountName, accountKey, nil
+	return fmt.Sprintf("https://%s.blob.core.windows.net", aa.Name)
 }
 
-func getAccountNameKeyAndCredential() (string, string, azblob.Credential, error) {
-	var err error = nil
-	var accountCredential azblob.Credential = nil
+func (aa azureAccount) credential() (azblob.Credential, error) {
+	return azblob.NewSharedKeyCredential(aa.Name, aa.Key)
+}
+
+// Parses an Azure storage connection string such as:
+//     DefaultEndpointsProtocol=https;AccountName=pithub;AccountKey=...;EndpointSuffix=core.windows.net
+//     DefaultEndpointsProtocol=http;AccountName=devstoreaccount1;AccountKey=...;BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1;
+//     UseDevelopmentStorage=true
+func parseAzureConnectionString(connectionString string) (azureAccount, error) {
+	var account azureAccount
+	settings := map[string]string{}
+	for _, setting := range strings.Split(connectionString, ";") {
+		setting = strings.TrimSpace(setting)
+		if len(setting) == 0 {
+			continue
+		}
 
-	accountName, accountKey, err := getAccountNameAndKey()
-	if err != nil {
-		return accountName, accountKey, accountCredential, err
+		// Account keys are base64 encoded and can end with "=" so only split on the first "=".
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			return account, errors.New(fmt.Sprintf("Connection string setting \"%s\" is not valid", setting))
+		}
+		settings[strings.ToLower(parts[0])] = parts[1]
 	}
 
-	accountCredential, err = azblob.NewSharedKeyCredential(accountName, accountKey)
-	return accountName, accountKey, accountCredential, err
-}
+	if strings.EqualFold(settings["usedevelopmentstorage"], "true") {
+		account.Name = azuriteAccountName
+		account.Key = azuriteAccountKey
+		account.Endpoint = azuriteBlobEndpoint
+		return account, nil
+	}
 
-func getContainerURL(containerName string) (azblob.ContainerURL, error) {
-	accountName, _, accountCredential, err := getAccountNameKeyAndCredential()
-	p := azblob.NewPipeline(accountCredential, azblob.PipelineOptions{})
+	account.Name = settings["accountname"]
+	account.Key = settings["accountkey"]
+	account.Endpoint = settings["blobendpoint"]
+	if len(account.Endpoint) == 0 && len(settings["endpointsuffix"]) != 0 {
+		protocol := settings["defaultendpointsprotocol"]
+		if len(protocol) == 0 {
+			protocol = "https"
+		}
+		account.Endpoint = fmt.Sprintf("%s://%s.blob.%s", protocol, account.Name, settings["endpointsuffix"])
+	}
 
-	// From the Azure portal, get your storage account blob service URL endpoint.
-	URL, _ := url.Parse(
-		fmt.Sprintf("https://%s.blob.core.windows.net/%s", accountName, containerName)) 
+	if len(account.Name) == 0 || len(account.Key) == 0 {
+		return account, errors.New("Connection string must include AccountName and AccountKey")
+	}
 
-	// Create a ContainerURL object that wraps the container URL and a request
-	// pipeline to make requests.
-	containerURL := azblob.NewContainerURL(*URL, p)
-	return containerURL, err
+	return account, nil
 }
 
-func createPublicContainer(containerName string) {
-	log.Println(fmt.Sprintf("createPublicContainer(containerName=%s)", containerName))
-	containerURL, err := getContainerURL(containerName)
-	handleAzureErrors(err)
-	
-	ctx := context.Background()
-	_, err2 := containerURL.Create(ctx, azblob.Metadata{}, azblob.PublicAccessBlob)
-	// Consider other options for private or fully public containers including:
-	//     azblob.PublicAccessContainer 
-	//     azblob.PublicAccessNone 
-	handleAzureErrors(err2)
+// Returns the Azure storage account of the Container. The account is taken from (in order of precedence):
+//     The AZURE_STORAGE_CONNECTION_STRING environment variable.
+//     The AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_ACCESS_KEY environment variables.
+//     The Account, Key, and Endpoint fields of the Container, the Key is read from the credential store.
+func getAzureAccount(container containerProperties) (azureAccount, error) {
+	account, err := lookupAzureAccount(container)
+	if err == nil && (len(account.Name) == 0 || len(account.Key) == 0) {
+		err = fmt.Errorf("%w: either the AZURE_STORAGE_ACCOUNT or AZURE_STORAGE_ACCESS_KEY environment variable is not set", ErrAuth)
+	}
+	return account, err
 }
 
-func deleteContainer(containerName string) error {
-	log.Println(fmt.Sprintf("deleteContainer(containerName=%s)", containerName))
-	if !strings.Contains(containerName, "testtest") {
-		log.Println(fmt.Sprintf("Fatal Error: Attempting to delete a non-test Container named %s", containerName))
-		return errors.New("The deleteContainer function only deletes containers with \"testtest\" in the name")
+// Returns the Azure storage account of the Container as getAzureAccount() does, but the key may be missing.
+func lookupAzureAccount(container containerProperties) (azureAccount, error) {
+	// From the Azure portal, get storage account name and key and set environment variables.
+	//     export AZURE_STORAGE_ACCOUNT="pithub"
+	//     export AZURE_STORAGE_ACCESS_KEY="<key>"
+	// Or, for example for the Azurite emulator:
+	//     export AZURE_STORAGE_CONNECTION_STRING="UseDevelopmentStorage=true"
+	if connectionString := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); len(connectionString) != 0 {
+		return parseAzureConnectionString(connectionString)
 	}
 
-	containerURL, _ := getContainerURL(containerName)
-	ctx := context.Background() 
-	containerURL.Delete(ctx, azblob.ContainerAccessConditions{})
-	return nil
+	var account azureAccount
+	account.Name, account.Key = os.Getenv("AZURE_STORAGE_ACCOUNT"), os.Getenv("AZURE_STORAGE_ACCESS_KEY")
+	if len(account.Name) == 0 || len(account.Key) == 0 {
+		account.Name = container.Account
+		account.Key = container.Key
+	}
+	account.Endpoint = container.Endpoint
+	return account, nil
 }
-
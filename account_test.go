@@ -0,0 +1,76 @@
+package pit
+
+import (
+	"testing"
+)
+
+func TestParseAzureConnectionString(t *testing.T) {
+	tests := []struct {
+		connectionString string
+		expected         azureAccount
+		valid            bool
+	}{
+		{"UseDevelopmentStorage=true", azureAccount{azuriteAccountName, azuriteAccountKey, azuriteBlobEndpoint}, true},
+		{"usedevelopmentstorage=TRUE;", azureAccount{azuriteAccountName, azuriteAccountKey, azuriteBlobEndpoint}, true},
+		{"AccountName=pithub;AccountKey=a2V5cw==", azureAccount{"pithub", "a2V5cw==", ""}, true},
+		{"AccountName=pithub;AccountKey=a2V5cw==;BlobEndpoint=http://nas:10000/pithub",
+			azureAccount{"pithub", "a2V5cw==", "http://nas:10000/pithub"}, true},
+		{"AccountName=pithub;AccountKey=a2V5cw==;EndpointSuffix=core.chinacloudapi.cn",
+			azureAccount{"pithub", "a2V5cw==", "https://pithub.blob.core.chinacloudapi.cn"}, true},
+		{"DefaultEndpointsProtocol=http;AccountName=pithub;AccountKey=a2V5cw==;EndpointSuffix=core.windows.net",
+			azureAccount{"pithub", "a2V5cw==", "http://pithub.blob.core.windows.net"}, true},
+		{"AccountName=pithub;AccountKey=a2V5cw==;EndpointSuffix=core.windows.net;BlobEndpoint=http://nas:10000/pithub",
+			azureAccount{"pithub", "a2V5cw==", "http://nas:10000/pithub"}, true},
+		{"AccountName=pithub", azureAccount{}, false},
+		{"AccountKey=a2V5cw==", azureAccount{}, false},
+		{"AccountName=pithub;AccountKey", azureAccount{}, false},
+		{"", azureAccount{}, false},
+	}
+
+	for _, test := range tests {
+		account, err := parseAzureConnectionString(test.connectionString)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("\"%s\" was parsed as %+v, expected an error", test.connectionString, account)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\"%s\" was not parsed: %v", test.connectionString, err)
+		} else if account != test.expected {
+			t.Errorf("\"%s\" was parsed as %+v, expected %+v", test.connectionString, account, test.expected)
+		}
+	}
+}
+
+func TestAzureDocumentURLs(t *testing.T) {
+	for _, name := range []string{"AZURE_STORAGE_CONNECTION_STRING", "AZURE_STORAGE_ACCOUNT", "AZURE_STORAGE_ACCESS_KEY"} {
+		t.Setenv(name, "")
+	}
+	tests := []struct {
+		endpoint         string
+		connectionString string
+		expected         string
+	}{
+		{"", "", "https://pithub.blob.core.windows.net/videos/intro.mp4"},
+		{"http://127.0.0.1:10000/devstoreaccount1/", "", "http://127.0.0.1:10000/devstoreaccount1/videos/intro.mp4"},
+		{"https://pithub.blob.core.usgovcloudapi.net", "", "https://pithub.blob.core.usgovcloudapi.net/videos/intro.mp4"},
+		{"", "UseDevelopmentStorage=true", "http://127.0.0.1:10000/devstoreaccount1/videos/intro.mp4"},
+		// The connection string takes precedence over the Container.
+		{"https://pithub.blob.core.windows.net", "AccountName=other;AccountKey=a2V5cw==;EndpointSuffix=core.chinacloudapi.cn",
+			"https://other.blob.core.chinacloudapi.cn/videos/intro.mp4"},
+	}
+
+	for _, test := range tests {
+		t.Setenv("AZURE_STORAGE_CONNECTION_STRING", test.connectionString)
+		backend, err := newAzureBackend(containerProperties{Type: "azure", Name: "videos", Account: "pithub",
+			Key: azuriteAccountKey, Endpoint: test.endpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if url := backend.URL("intro.mp4"); url != test.expected {
+			t.Errorf("the URL with endpoint \"%s\" and connection string \"%s\" is %s, expected %s",
+				test.endpoint, test.connectionString, url, test.expected)
+		}
+	}
+}
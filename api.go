@@ -0,0 +1,337 @@
+/*
+	The API lets other Go programs use pit without running the pit command, e.g.:
+
+		client, err := pit.NewClient()
+		collection, err := client.OpenCollection("/Users/me/videos")
+		err = collection.Add(ctx, "intro.mp4")
+		report, err := collection.Push(ctx, pit.PushOptions{Jobs: 8})
+
+	Operations return errors instead of exiting the application, and the outcome of every document is returned as
+	a Report. Each operation reads and writes the files of its collection relative to the collection folder, and
+	writes its text output to the Output of the Client, so the working directory and stdout of the application are
+	not changed.
+
+	The context of an operation is passed to every Backend call, so a transfer in progress is cancelled with the
+	context.
+*/
+package pit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Operations run one at a time because they read and write account.json and the credential store.
+var operationMutex sync.Mutex
+
+// A Client runs operations with the Containers in the user's account.json.
+type Client struct {
+	// If Verbose is true, operations print the same output as the pit command to Output.
+	Verbose bool
+
+	// Output is where operations print their output if Verbose is true. If nil, stdout is used.
+	Output io.Writer
+
+	// Profile selects the profile in account.json that operations use. If empty, the PIT_PROFILE environment
+	// variable or the active profile is used.
+	Profile string
+
+	// Passphrase is called to ask for the passphrase of the credential store, see "pit credential set". If nil,
+	// the credential store can only be unlocked with the key file named by the PIT_KEY_FILE environment variable.
+	Passphrase func(prompt string) (string, error)
+}
+
+// An operation is one command run by a Client, e.g. a push. It is passed to the functions that implement the
+// command.
+type operation struct {
+	ctx        context.Context
+	dir        string         // The collection folder, or "" for the working directory, e.g. before a clone.
+	out        io.Writer      // The text output of the command.
+	profile    string         // See Client.Profile.
+	passphrase passphraseFunc // See Client.Passphrase.
+
+	report      *Report // The outcome of every document, nil if the report is not recorded (see report.go).
+	reportMutex sync.Mutex
+
+	outputMutex      sync.Mutex // Serializes the output of the workers (see transfer.go).
+	progressLineOpen bool       // True while a download progress line without a newline is displayed.
+	activeDownloads  int
+}
+
+// Returns the path of the file with the name relative to the collection folder, e.g. "clips/intro.mp4".
+func (op *operation) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(op.dir, name)
+}
+
+// A Collection is a collection folder, i.e. a folder with a .pit.json file.
+type Collection struct {
+	client *Client
+	dir    string
+}
+
+// Version returns the version of pit, e.g. "1.3.4".
+func Version() string {
+	return productVersion
+}
+
+// NewClient returns a Client after creating the user app folder and account.json if they do not exist yet.
+func NewClient() (*Client, error) {
+	account := new(accountProperties)
+	err := account.verify()
+	if err != nil {
+		return nil, err
+	}
+	return new(Client), nil
+}
+
+// OpenLogFile opens the log file in the user app folder for appending. Logging is enabled by creating the file.
+func OpenLogFile() (*os.File, error) {
+	account := new(accountProperties)
+	return os.OpenFile(filepath.Join(account.userAppPath(), userAppLogFileName), os.O_APPEND|os.O_RDWR, 0666)
+}
+
+// OpenCollection returns the collection in dir using a new Client.
+func OpenCollection(dir string) (*Collection, error) {
+	client, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.OpenCollection(dir)
+}
+
+// OpenCollection returns the collection in dir, which must have been initialized or cloned.
+func (client *Client) OpenCollection(dir string) (*Collection, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fileExists(filepath.Join(dir, pitFileName)) {
+		return nil, errors.New(fmt.Sprintf("No collection initialized in %s", dir))
+	}
+	return &Collection{client: client, dir: dir}, nil
+}
+
+// Runs the operation f in dir and returns its report.
+func (client *Client) run(ctx context.Context, dir string, command string, f func(op *operation) error) (*Report, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(dir) != 0 {
+		var err error
+		dir, err = filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var out io.Writer = ioutil.Discard
+	if client.Verbose {
+		out = client.Output
+		if out == nil {
+			out = os.Stdout
+		}
+	}
+
+	operationMutex.Lock()
+	defer operationMutex.Unlock()
+
+	op := &operation{ctx: ctx, dir: dir, out: out, profile: client.Profile, passphrase: client.Passphrase,
+		report: &Report{Command: command}}
+	err := f(op)
+	if err != nil {
+		op.reportCommandError(err)
+		fmt.Fprintf(op.out, "Error: %s\n", err)
+	}
+
+	report := op.finishReport()
+	if err == nil {
+		err = report.err
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return report, err
+}
+
+// Init initializes a new collection in dir, which is created if needed.
+func (client *Client) Init(ctx context.Context, dir string) (*Collection, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = client.run(ctx, dir, "init", func(op *operation) error {
+		return collectionInitialize(op)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.OpenCollection(dir)
+}
+
+// Clone copies the collection with the given local name, remote name, or manifest URL into dir, or into a folder
+// named after the collection if dir is empty. Relative folders are relative to the working directory.
+func (client *Client) Clone(ctx context.Context, source string, dir string, options CloneOptions) (*Collection, *Report, error) {
+	var collectionDir string
+	report, err := client.run(ctx, "", "clone", func(op *operation) error {
+		err := collectionClone(op, source, dir, options)
+		if err == nil {
+			collectionDir = op.dir
+		}
+		return err
+	})
+	if err != nil || len(collectionDir) == 0 {
+		return nil, report, err
+	}
+
+	collection, err := client.OpenCollection(collectionDir)
+	return collection, report, err
+}
+
+// Clone copies a collection using a new Client (see Client.Clone).
+func Clone(ctx context.Context, source string, dir string, options CloneOptions) (*Collection, *Report, error) {
+	client, err := NewClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Clone(ctx, source, dir, options)
+}
+
+// CollectionNames returns the local names of the collections that were pushed or cloned with this account.
+func (client *Client) CollectionNames() ([]string, error) {
+	account := new(accountProperties)
+	err := account.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, col := range account.Collections {
+		names = append(names, col.NameLocal)
+	}
+	return names, nil
+}
+
+// Dir returns the folder of the collection.
+func (collection *Collection) Dir() string {
+	return collection.dir
+}
+
+// Status verifies every document of the collection with its remote document and lists untracked files.
+func (collection *Collection) Status(ctx context.Context) (*Report, error) {
+	return collection.client.run(ctx, collection.dir, "status", func(op *operation) error {
+		return collectionStatus(op)
+	})
+}
+
+// DocumentNames returns the names of the documents in the collection, e.g. "clips/intro.mp4".
+func (collection *Collection) DocumentNames(ctx context.Context) ([]string, error) {
+	var names []string
+	_, err := collection.client.run(ctx, collection.dir, "documents", func(op *operation) error {
+		props, err := collectionRead(op)
+		for _, doc := range props.Documents {
+			names = append(names, doc.NameLocal)
+		}
+		return err
+	})
+	return names, err
+}
+
+// Add adds, or updates, the files, the files in the folders, and the files that match the glob patterns. Paths
+// are relative to the collection folder.
+func (collection *Collection) Add(ctx context.Context, paths ...string) error {
+	_, err := collection.client.run(ctx, collection.dir, "add", func(op *operation) error {
+		return collectionAddPaths(op, paths, false)
+	})
+	return err
+}
+
+// AddAll adds, or updates, every file in the collection folder that is not ignored by .pitignore.
+func (collection *Collection) AddAll(ctx context.Context) error {
+	_, err := collection.client.run(ctx, collection.dir, "add", func(op *operation) error {
+		return collectionAddPaths(op, nil, true)
+	})
+	return err
+}
+
+// RemoveOptions control how Collection.Remove() removes a document.
+type RemoveOptions struct {
+	Cached  bool // Keep the remote document and only stop tracking the document.
+	Archive bool // Copy the remote document to the archive before the next push deletes it.
+}
+
+// Remove removes the document from the collection. The next push deletes the remote document.
+func (collection *Collection) Remove(ctx context.Context, name string, options RemoveOptions) error {
+	_, err := collection.client.run(ctx, collection.dir, "rm", func(op *operation) error {
+		return collectionRemove(op, name, options.Cached, options.Archive)
+	})
+	return err
+}
+
+// Move renames the document. The next push moves the remote document and, if keepAlias is true, keeps a copy at
+// the old URL.
+func (collection *Collection) Move(ctx context.Context, oldName string, newName string, keepAlias bool) error {
+	_, err := collection.client.run(ctx, collection.dir, "mv", func(op *operation) error {
+		return collectionMove(op, oldName, newName, keepAlias)
+	})
+	return err
+}
+
+// SetRemote binds the collection to the Container of the profile. If the Container changes, the next push uploads
+// every document to the new Container.
+func (collection *Collection) SetRemote(ctx context.Context, profile string) error {
+	_, err := collection.client.run(ctx, collection.dir, "remote", func(op *operation) error {
+		return collectionSetRemote(op, profile)
+	})
+	return err
+}
+
+// Remote describes the Container that a collection is bound to.
+type Remote struct {
+	Name    string `json:"name"`    // The local name of the collection.
+	Profile string `json:"profile"` // The profile the collection was bound with, or "" for a Container that is not in account.json.
+	Type    string `json:"type"`    // Values: "azure", "file", "s3", or "url" for a Container that is read over plain HTTP(S).
+	URL     string `json:"url"`     // The URL of the Container, or "" until the collection is bound by its first push.
+}
+
+// Remote returns the Container, and the profile, that the collection is bound to.
+func (collection *Collection) Remote(ctx context.Context) (Remote, error) {
+	var remote Remote
+	_, err := collection.client.run(ctx, collection.dir, "remote", func(op *operation) error {
+		var err error
+		remote, err = collectionRemote(op)
+		return err
+	})
+	return remote, err
+}
+
+// Push uploads the new and updated documents and the collection.
+func (collection *Collection) Push(ctx context.Context, options PushOptions) (*Report, error) {
+	return collection.client.run(ctx, collection.dir, "push", func(op *operation) error {
+		return collectionPush(op, options)
+	})
+}
+
+// Pull downloads the documents that were updated by other computers. With force, local changes are overwritten.
+func (collection *Collection) Pull(ctx context.Context, force bool) (*Report, error) {
+	return collection.client.run(ctx, collection.dir, "pull", func(op *operation) error {
+		return collectionPull(op, force)
+	})
+}
+
+// Fetch downloads the documents that match the glob patterns, e.g. documents not downloaded by a sparse clone.
+func (collection *Collection) Fetch(ctx context.Context, patterns []string, jobs int) (*Report, error) {
+	return collection.client.run(ctx, collection.dir, "fetch", func(op *operation) error {
+		return collectionFetch(op, patterns, jobs)
+	})
+}
@@ -0,0 +1,106 @@
+package pit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientAddPushStatusClone(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdirComputer("computer1")
+	ctx := context.Background()
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection, err := client.Init(ctx, "videos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(collection.Dir(), "intro.mp4"), []byte("intro"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := collection.Add(ctx, "intro.mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := collection.Push(ctx, PushOptions{})
+	if err != nil || report.Failed || report.Summary[StateVerified] != 1 {
+		t.Fatalf("unexpected push report %+v, %v", report, err)
+	}
+	report, err = collection.Status(ctx)
+	if err != nil || report.Failed || report.Collection != "videos" || report.Summary[StateVerified] != 1 {
+		t.Fatalf("unexpected status report %+v, %v", report, err)
+	}
+
+	// Operations do not change the working directory of the application.
+	if cwd, _ := os.Getwd(); filepath.Base(cwd) != "computer1" {
+		t.Errorf("working directory changed to %s", cwd)
+	}
+
+	te.chdirComputer("computer2")
+	cloned, report, err := client.Clone(ctx, "videos", "", CloneOptions{})
+	if err != nil || report.Failed {
+		t.Fatalf("unexpected clone report %+v, %v", report, err)
+	}
+	if content := readTestFile(t, filepath.Join(cloned.Dir(), "intro.mp4")); content != "intro" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestClientReturnsErrors(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdirComputer("computer1")
+	client := new(Client)
+
+	if _, err := client.OpenCollection("videos"); err == nil {
+		t.Error("opened a collection that was not initialized")
+	}
+
+	collection, err := client.Init(context.Background(), "videos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := collection.Add(context.Background(), "missing.mp4"); err == nil {
+		t.Error("added a document that does not exist")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := collection.Status(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestClientOutput(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdirComputer("computer1")
+	ctx := context.Background()
+
+	var output bytes.Buffer
+	client := &Client{Verbose: true, Output: &output}
+	collection, err := client.Init(ctx, "videos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collection.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, output.String(), "Collection \"videos\"")
+
+	// Without Verbose, the output is discarded.
+	output.Reset()
+	client.Verbose = false
+	if _, err := collection.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if output.Len() != 0 {
+		t.Errorf("unexpected output %q", output.String())
+	}
+}
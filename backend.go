@@ -0,0 +1,297 @@
+/*
+	Backend represents the remote store that holds the documents of a Container. Collection logic (push, status,
+	clone) only talks to a Backend so that additional stores can be added without touching the collection code.
+	The Backend implementation is selected by containerProperties.Type.
+*/
+package pit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Conditions that must hold for Backend.Put(), Backend.Copy() or Backend.SetMetadata() to succeed. The zero value always succeeds.
+type accessConditions struct {
+	IfMatch     string // The remote ETag (without double quotes) must match.
+	IfNoneMatch string // The value "*" requires that the remote document does not exist.
+}
+
+const etagAny = "*"
+
+// Returns conditions that only succeed if the remote document still has the given ETag, or does not exist yet if
+// the ETag is empty.
+func accessConditionsForETag(etag string) accessConditions {
+	if len(etag) == 0 {
+		return accessConditions{IfNoneMatch: etagAny}
+	}
+	return accessConditions{IfMatch: etag}
+}
+
+// Properties of a remote document as reported by Backend.Stat().
+type remoteDocument struct {
+	Name         string
+	ETag         string // Without the leading and trailing double quotes.
+	MD5          string // Value of the pitmd5 metadata, empty if not set.
+	Size         int64
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
+type Backend interface {
+	// Put uploads the local file as remoteName, replacing any existing remote document, and returns the new ETag.
+	Put(ctx context.Context, localName string, remoteName string, conditions accessConditions) (string, error)
+
+	// Get writes the content of remoteName, starting at offset, to w. An offset greater than 0 resumes an
+	// interrupted download.
+	Get(ctx context.Context, remoteName string, offset int64, w io.Writer) error
+
+	// Stat returns the properties and metadata of remoteName.
+	Stat(ctx context.Context, remoteName string) (remoteDocument, error)
+
+	// List returns the names of all remote documents that start with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Delete removes remoteName, provided it matches the access conditions, otherwise a ConflictError is returned.
+	Delete(ctx context.Context, remoteName string, conditions accessConditions) error
+
+	// Copy copies sourceRemoteName, including its metadata, to remoteName without downloading it and returns the
+	// ETag of remoteName. The conditions apply to remoteName.
+	Copy(ctx context.Context, sourceRemoteName string, remoteName string, conditions accessConditions) (string, error)
+
+	// SetMetadata adds or replaces the given metadata on remoteName and returns the new ETag. Existing metadata is
+	// preserved.
+	SetMetadata(ctx context.Context, remoteName string, metadata map[string]string, conditions accessConditions) (string, error)
+
+	// URL returns the URL at which remoteName is shared.
+	URL(remoteName string) string
+}
+
+// A blockBackend is a Backend that can upload a document in blocks. Staged blocks only become the content of the
+// document when the block list is committed, so an interrupted upload can be resumed (see upload.go).
+type blockBackend interface {
+	Backend
+
+	// StageBlock uploads one block of remoteName. The blockID must be base64 encoded and all block IDs of a
+	// document must have the same length.
+	StageBlock(ctx context.Context, remoteName string, blockID string, data []byte) error
+
+	// StagedBlocks returns the IDs of the blocks of remoteName that are staged but not yet committed.
+	StagedBlocks(ctx context.Context, remoteName string) ([]string, error)
+
+	// CommitBlocks replaces the content of remoteName with the given staged blocks and returns the new ETag.
+	CommitBlocks(ctx context.Context, remoteName string, blockIDs []string, conditions accessConditions) (string, error)
+}
+
+// Returns the Backend for the default Container, i.e. the Container of the selected or active profile. Collections
+// are bound to the Container they were created in, so this is only used by "pit init" and to clone or push
+// collections that are not bound yet.
+func newBackend(op *operation) (Backend, error) {
+	container, err := selectedContainer(op.profile)
+	if err != nil {
+		return nil, err
+	}
+	return newContainerBackend(container, op.passphrase)
+}
+
+// Returns the Container of the selected or active profile.
+func selectedContainer(profile string) (containerProperties, error) {
+	account := new(accountProperties)
+	container, err := account.defaultContainer(profile)
+	if err != nil {
+		return container, err
+	}
+
+	// The AZURE_CONTAINER_NAME environment variable overrides the Container name.
+	if containerName := os.Getenv("AZURE_CONTAINER_NAME"); len(containerName) != 0 {
+		container.Name = containerName
+	}
+	return container, nil
+}
+
+// Returns the backend of the Container that holds the collection, i.e. the Container with the URL recorded in the
+// collection or the default Container if the collection is not bound yet. A profile selected with "--profile" or
+// PIT_PROFILE must be the profile of the Container the collection is bound to.
+func newCollectionBackend(op *operation, props collectionProperties) (Backend, error) {
+	if len(props.URL) == 0 {
+		return newBackend(op)
+	}
+
+	if name := profileSelection(op.profile); len(name) != 0 {
+		container, err := selectedContainer(name)
+		if err != nil {
+			return nil, err
+		}
+		containerURL, err := container.containerURL()
+		if err != nil {
+			return nil, err
+		}
+		if containerURL != strings.TrimSuffix(props.URL, "/") {
+			return nil, errors.New(fmt.Sprintf("The collection is bound to %s, not to profile \"%s\" (use \"pit remote set %s\" "+
+				"to bind it to the profile)", props.URL, name, name))
+		}
+		return newContainerBackend(container, op.passphrase)
+	}
+
+	return newContainerURLBackend(op, props.URL)
+}
+
+// Returns the URL of the Container of the backend, e.g. "https://pithub.blob.core.windows.net/nvm4zqwm".
+func backendContainerURL(backend Backend) string {
+	return strings.TrimSuffix(backend.URL(""), "/")
+}
+
+// Returns the URL of the Container, e.g. "https://pithub.blob.core.windows.net/nvm4zqwm", without reading its
+// credentials, so that finding the Container of a URL does not unlock the credential store.
+func (cp containerProperties) containerURL() (string, error) {
+	switch strings.ToLower(cp.Type) {
+	case "", "azure":
+		account, err := lookupAzureAccount(cp)
+		if err != nil {
+			return "", err
+		} else if len(account.Name) == 0 {
+			return "", errors.New(fmt.Sprintf("Container \"%s\" does not have a storage account", cp.Name))
+		}
+		u, err := url.Parse(account.serviceURL() + "/" + cp.Name)
+		if err != nil {
+			return "", err
+		}
+		return u.String(), nil
+	case "file":
+		backend, err := newFileBackend(cp)
+		if err != nil {
+			return "", err
+		}
+		return backendContainerURL(backend), nil
+	case "s3":
+		backend, err := newS3BucketBackend(cp)
+		if err != nil {
+			return "", err
+		}
+		return backendContainerURL(backend), nil
+	}
+
+	return "", errors.New(fmt.Sprintf("Unsupported container type \"%s\"", cp.Type))
+}
+
+// Returns the backend of the Container in account.json with the given URL. Containers that are not in account.json
+// are read over plain HTTP(S).
+func newContainerURLBackend(op *operation, containerURL string) (Backend, error) {
+	containerURL = strings.TrimSuffix(containerURL, "/")
+
+	account := new(accountProperties)
+	err := account.read()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, container := range account.Containers {
+		if u, err := container.containerURL(); err == nil && u == containerURL {
+			return newContainerBackend(container, op.passphrase)
+		}
+	}
+
+	if strings.HasPrefix(containerURL, "http://") || strings.HasPrefix(containerURL, "https://") {
+		return newURLBackend(containerURL), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Container %s not found in %s", containerURL, userAppAccountFileName))
+}
+
+func newContainerBackend(container containerProperties, passphrase passphraseFunc) (Backend, error) {
+	err := container.addCredential(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(container.Type) {
+	case "", "azure":
+		return newAzureBackend(container)
+	case "file":
+		return newFileBackend(container)
+	case "s3":
+		return newS3Backend(container)
+	}
+
+	return nil, errors.New(fmt.Sprintf("Unsupported container type \"%s\"", container.Type))
+}
+
+func uploadDocument(op *operation, backend Backend, localName string, remoteName string, conditions accessConditions) (string, error) {
+	if localName != pitFileName {
+		op.printLine("Uploading: %s...", localName)
+	} else {
+		log.Println(fmt.Sprintf("Uploading: %s...", localName))
+	}
+
+	// Large documents are uploaded in blocks so that an interrupted upload can be resumed.
+	if bb, ok := backend.(blockBackend); ok && localName != pitFileName {
+		info, err := os.Stat(op.path(localName))
+		if err != nil {
+			return "", err
+		}
+		if info.Size() > uploadBlockSize {
+			return uploadDocumentInBlocks(op, bb, localName, remoteName, conditions)
+		}
+	}
+
+	return backend.Put(op.ctx, op.path(localName), remoteName, conditions)
+}
+
+func setDocumentMetadataMD5(ctx context.Context, backend Backend, remoteName string, md5 string, conditions accessConditions) (string, error) {
+	return backend.SetMetadata(ctx, remoteName, map[string]string{pitMD5tag: md5}, conditions)
+}
+
+func getRemoteFileMD5AndETag(ctx context.Context, backend Backend, remoteFileName string) (string, string, error) {
+	remoteDoc, err := backend.Stat(ctx, remoteFileName)
+	if err != nil {
+		return "", "", err
+	}
+
+	return remoteDoc.MD5, remoteDoc.ETag, nil
+}
+
+func getDocumentNames(ctx context.Context, backend Backend) []string {
+	documentNames, err := backend.List(ctx, "")
+	if err != nil {
+		log.Println(fmt.Sprintf("Unable to list documents: %s", err))
+	}
+
+	return documentNames
+}
+
+func listBlobs(op *operation, backend Backend, containerName string) {
+	documentNames := getDocumentNames(op.ctx, backend)
+	if len(documentNames) == 0 {
+		fmt.Fprintf(op.out, "No files currently in container \"%s\"\n", containerName)
+	} else {
+		fmt.Fprintf(op.out, "Files in container \"%s\":\n", containerName)
+		for _, documentName := range documentNames {
+			fmt.Fprintln(op.out, "    "+documentName)
+		}
+		fmt.Fprintf(op.out, "Number of documents %d\n", len(documentNames))
+	}
+}
+
+// Returns the content type for known file extensions, or an empty string.
+func contentType(remoteName string) string {
+	ext := filepath.Ext(remoteName)
+	if strings.EqualFold(ext, ".json") {
+		return "application/json"
+	} else if strings.EqualFold(ext, ".mp4") {
+		return "video/mp4"
+	} else if strings.EqualFold(ext, ".docx") {
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	} else if strings.EqualFold(ext, ".pdf") {
+		return "application/pdf"
+	} else if strings.EqualFold(ext, ".html") {
+		return "text/html"
+	}
+
+	return ""
+}
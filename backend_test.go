@@ -0,0 +1,38 @@
+package pit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewContainerBackendSelectsType(t *testing.T) {
+	tests := []struct {
+		containerType string
+		expected      string
+	}{
+		{"", "*pit.azureBackend"},
+		{"azure", "*pit.azureBackend"},
+		{"Azure", "*pit.azureBackend"},
+		{"file", "*pit.fileBackend"},
+		{"s3", "*pit.s3Backend"},
+		{"S3", "*pit.s3Backend"},
+	}
+
+	te := newTestEnvironmentWithContainer(t, containerProperties{Name: testContainerName})
+	for _, test := range tests {
+		container := containerProperties{Type: test.containerType, Name: testContainerName, Account: "pithub",
+			Key: azuriteAccountKey, Path: te.root}
+		backend, err := newContainerBackend(container, nil)
+		if err != nil {
+			t.Errorf("type \"%s\" failed: %v", test.containerType, err)
+		} else if backendType := reflect.TypeOf(backend).String(); backendType != test.expected {
+			t.Errorf("type \"%s\" selected %s, expected %s", test.containerType, backendType, test.expected)
+		}
+	}
+
+	_, err := newContainerBackend(containerProperties{Type: "ftp", Name: testContainerName}, nil)
+	if err == nil || !strings.Contains(err.Error(), "Unsupported container type \"ftp\"") {
+		t.Errorf("expected an error for an unsupported type, got %v", err)
+	}
+}
@@ -1,95 +1,402 @@
-package main
+package pit
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
-func collectionClone(localName string) {
-	userAccount := new(accountProperties)
-	remoteName, _ := userAccount.getRemoteName(localName)
-	collectionJSONFileName := remoteName+".json"
-
-	err := os.Mkdir(localName, os.ModePerm)
-	check(err)
-	
-	err = os.Chdir(localName)
-	check(err)
-	
-	// Todo: Update so that path is not hard coded.
-	remoteCollectionURL := "https://pithub.blob.core.windows.net/nvm4zqwm/"+collectionJSONFileName
-
-	err = DownloadFile(pitFileName, remoteCollectionURL)
-	check(err)
-
-	props, err := collectionRead()
-	check(err)
-	
-	// Download all files in the collection.
-	for _, doc := range props.Documents {
-		_, remoteFileURL := getRemoteFileNameAndURL(props, doc.NameLocal)
-
-		err := DownloadFile(doc.NameLocal, remoteFileURL)
-		check(err)
-	}
+type CloneOptions struct {
+	Jobs int      // The number of documents downloaded at the same time.
+	Only []string // If not empty, only documents that match one of the glob patterns (e.g. "*.mp4") are downloaded.
 }
 
-// The code below was initially taken from "https://golangcode.com/download-a-file-with-progress/"
+// Returns true if the name of the document matches the glob pattern.
+func documentMatches(nameLocal string, pattern string) bool {
+	return matchGlob(pattern, nameLocal)
+}
 
-// WriteCounter counts the number of bytes written to it. It implements to the io.Writer interface
-// and we can pass this into io.TeeReader() which will report progress on each write cycle.
-type WriteCounter struct {
-	Total uint64
+// Splits a manifest URL (e.g. "https://pithub.blob.core.windows.net/nvm4zqwm/abcdefgh.json") into the URL of the
+// Container and the remote name of the collection.
+func parseManifestURL(manifestURL string) (string, string, error) {
+	u, err := url.Parse(manifestURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	index := strings.LastIndex(u.Path, "/")
+	if index < 0 || !strings.HasSuffix(u.Path, ".json") {
+		return "", "", errors.New(fmt.Sprintf("%s is not the URL of a collection (<container-url>/<remote-name>.json)", manifestURL))
+	}
+
+	nameRemote := strings.TrimSuffix(u.Path[index+1:], ".json")
+	u.Path = u.Path[:index]
+	u.RawPath = ""
+	u.RawQuery = ""
+	return u.String(), nameRemote, nil
 }
 
-func (wc *WriteCounter) Write(p []byte) (int, error) {
-	n := len(p)
-	wc.Total += uint64(n)
-	wc.PrintProgress()
-	return n, nil
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "file://")
 }
 
-var downloadFileName string = ""
-func (wc WriteCounter) PrintProgress() {
-	// Return again and print current status of download
-	fmt.Printf("\r%s...%d downloaded (kb)", downloadFileName, wc.Total/1000)
+// Returns the folder that a collection with the local name is cloned into, i.e. the last folder of the name, so that
+// the name of a remote collection cannot select a folder outside the working directory.
+func cloneFolderName(nameLocal string) (string, error) {
+	segments := strings.FieldsFunc(nameLocal, func(r rune) bool { return r == '/' || r == '\\' })
+	if len(segments) == 0 || segments[len(segments)-1] == "." || segments[len(segments)-1] == ".." ||
+		len(filepath.VolumeName(segments[len(segments)-1])) != 0 {
+		return "", errors.New(fmt.Sprintf("Collection name \"%s\" is not a valid folder name, give the folder to clone into", nameLocal))
+	}
+	return segments[len(segments)-1], nil
 }
 
-func DownloadFile(filepath string, url string) error {
-	downloadFileName = filepath
+// Returns the backend and remote name of the collection to clone. The source is the local or remote name of a
+// collection in account.json, the remote name of a collection in the default Container, or a manifest URL.
+func resolveCloneSource(op *operation, source string) (Backend, string, error) {
+	if isURL(source) {
+		containerURL, nameRemote, err := parseManifestURL(source)
+		if err != nil {
+			return nil, "", err
+		}
+		backend, err := newContainerURLBackend(op, containerURL)
+		return backend, nameRemote, err
+	}
+
+	userAccount := new(accountProperties)
+	col, ok, err := userAccount.findCollection(source)
+	if err != nil {
+		return nil, "", err
+	} else if ok {
+		backend, err := newCollectionBackend(op, collectionProperties{URL: col.URL})
+		return backend, col.NameRemote, err
+	}
+
+	backend, err := newBackend(op)
+	return backend, source, err
+}
+
+func collectionClone(op *operation, source string, dir string, options CloneOptions) error {
+	backend, nameRemote, err := resolveCloneSource(op, source)
+	if err != nil {
+		return err
+	}
+
+	props, err := readRemoteCollection(op.ctx, backend, nameRemote)
+	if errors.Is(err, ErrNotFound) {
+		return errors.New(fmt.Sprintf("Collection \"%s\" not found at %s", source, backend.URL(nameRemote+".json")))
+	} else if err != nil {
+		return err
+	}
+
+	// The collection is cloned into a directory with the name of the collection unless a directory is given.
+	if len(dir) == 0 {
+		dir, err = cloneFolderName(props.NameLocal)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Create the file, but give it a tmp file extension, this means we won't overwrite a
-	// file until it's downloaded, but we'll remove the tmp extension once downloaded.
-	out, err := os.Create(filepath + ".tmp")
+	// Re-running an interrupted clone resumes it.
+	err = os.MkdirAll(filepath.Dir(op.path(dir)), os.ModePerm)
 	if err != nil {
 		return err
 	}
+	err = os.Mkdir(op.path(dir), os.ModePerm)
+	if os.IsExist(err) && fileExists(op.path(filepath.Join(dir, pitFileName))) {
+		fmt.Fprintf(op.out, "Resuming clone of \"%s\"\n", dir)
+	} else if err != nil {
+		return err
+	}
 
-	// Get the data
-	resp, err := http.Get(url)
+	// The rest of the clone runs in the collection folder.
+	op.dir, err = filepath.Abs(op.path(dir))
 	if err != nil {
-		out.Close()
 		return err
 	}
-	defer resp.Body.Close()
 
-	// Create our progress reporter and pass it to be used alongside our writer
-	counter := &WriteCounter{}
-	if _, err = io.Copy(out, io.TeeReader(resp.Body, counter)); err != nil {
-		out.Close()
+	// The ETag of the remote Collection lets the next push detect a Collection uploaded in the meantime, and the URL
+	// binds the collection to its Container.
+	props.NameRemote = nameRemote
+	props.URL = backendContainerURL(backend)
+	props.Profile = new(accountProperties).profileForURL(props.URL)
+
+	// Documents that are not selected by --only are recorded as absent so they can be fetched later.
+	if len(options.Only) > 0 {
+		for i, doc := range props.Documents {
+			props.Documents[i].Absent = true
+			for _, pattern := range options.Only {
+				if documentMatches(doc.NameLocal, pattern) {
+					props.Documents[i].Absent = false
+				}
+			}
+		}
+	}
+
+	err = collectionWrite(op, props)
+	if err != nil {
 		return err
 	}
-	out.Close()
+	op.reportCollection(props.NameLocal)
 
-	// Trailing spaces are to overwrite previously written text.
-	fmt.Printf("\r%s...complete                   \n", downloadFileName)
+	userAccount := new(accountProperties)
+	err = userAccount.addBasicCollectionInfo(props.NameLocal, props.NameRemote, props.URL)
+	if err != nil {
+		return err
+	}
 
-	err = os.Rename(filepath+".tmp", filepath)
+	// Download all files in the collection. A failed download does not stop the others.
+	errs := make([]error, len(props.Documents))
+	forEachParallel(len(props.Documents), options.Jobs, func(index int) {
+		doc := props.Documents[index]
+		if doc.Absent {
+			return
+		}
+		if fileExists(op.path(doc.NameLocal)) {
+			// Downloaded before the clone was interrupted. A file with other content is not overwritten.
+			localMD5, err := md5File(op.path(doc.NameLocal))
+			if err != nil {
+				errs[index] = err
+			} else if localMD5 != doc.MD5 {
+				errs[index] = errors.New(fmt.Sprintf("%s exists and does not match the collection", doc.NameLocal))
+			}
+			return
+		}
+		errs[index] = downloadDocument(op, backend, getRemoteFileName(props, doc.NameLocal), doc.NameLocal, doc.MD5)
+	})
+
+	failures := 0
+	for index, err := range errs {
+		doc := props.Documents[index]
+		report := DocumentReport{Name: doc.NameLocal, State: StateVerified, LocalMD5: doc.MD5, RemoteMD5: doc.MD5, ETag: doc.ETag,
+			URL: backend.URL(getRemoteFileName(props, doc.NameLocal))}
+		if doc.Absent {
+			report.State = StateRemoteOnly
+			report.LocalMD5 = ""
+		} else if err != nil {
+			report.State = StateError
+			report.Error = err.Error()
+			op.printDocumentLine(doc.NameLocal, "Error: unable to download\n%s", err)
+			failures++
+		}
+		op.reportDocument(report)
+	}
+	if failures > 0 {
+		return errors.New(fmt.Sprintf("%d document(s) not downloaded. Run \"pit clone %s %s\" again to resume", failures, source, dir))
+	}
+	return nil
+}
+
+// Downloads the documents that match the glob patterns, e.g. documents that were not downloaded by a sparse clone.
+func collectionFetch(op *operation, patterns []string, jobs int) error {
+	props, err := collectionRead(op)
 	if err != nil {
 		return err
 	}
 
+	backend, err := newCollectionBackend(op, props)
+	if err != nil {
+		return err
+	}
+	op.reportCollection(props.NameLocal)
+
+	// A document that matches several patterns is only fetched once.
+	var indexes []int
+	selected := map[int]bool{}
+	for _, pattern := range patterns {
+		found := false
+		for index, doc := range props.Documents {
+			if documentMatches(doc.NameLocal, pattern) {
+				if !selected[index] {
+					indexes = append(indexes, index)
+					selected[index] = true
+				}
+				found = true
+			}
+		}
+		if !found {
+			op.reportDocument(DocumentReport{Name: pattern, State: StateError, Error: "not in the collection"})
+			op.printDocumentLine(pattern, "Error: not in the collection")
+		}
+	}
+
+	// Documents are downloaded by several workers, so each worker only updates its own document.
+	fetched := make([]bool, len(indexes))
+	forEachParallel(len(indexes), jobs, func(i int) {
+		doc := &props.Documents[indexes[i]]
+		remoteFileName, remoteFileURL := getRemoteFileNameAndURL(backend, props, doc.NameLocal)
+		report := DocumentReport{Name: doc.NameLocal, State: StateVerified, LocalMD5: doc.MD5, RemoteMD5: doc.MD5, ETag: doc.ETag,
+			URL: remoteFileURL}
+		if fileExists(op.path(doc.NameLocal)) {
+			localMD5, err := md5File(op.path(doc.NameLocal))
+			if err != nil {
+				report.State = StateError
+				report.Error = err.Error()
+				op.printDocumentLine(doc.NameLocal, "Error: unable to read\n%s", err)
+			} else if localMD5 != doc.MD5 {
+				// The local changes would be lost, so the remote version is in conflict with them.
+				report.State = StateConflict
+				report.LocalMD5 = localMD5
+				report.Error = "local changes have not been pushed"
+				op.printDocumentLine(doc.NameLocal, "Error: not fetched because local changes have not been pushed")
+			} else if !doc.Absent {
+				op.printDocumentLine(doc.NameLocal, "already downloaded")
+			} else {
+				doc.Absent = false
+				fetched[i] = true
+			}
+			op.reportDocument(report)
+			return
+		}
+
+		remoteMD5, remoteETag, err := getRemoteFileMD5AndETag(op.ctx, backend, remoteFileName)
+		if err != nil {
+			report.State = StateError
+			report.Error = err.Error()
+			op.reportDocument(report)
+			op.printDocumentLine(doc.NameLocal, "Error: unable to obtain MD5 or ETag for %s", remoteFileURL)
+			return
+		}
+
+		err = downloadDocument(op, backend, remoteFileName, doc.NameLocal, remoteMD5)
+		if err != nil {
+			report.State = StateError
+			report.Error = err.Error()
+			report.LocalMD5 = ""
+			report.RemoteMD5 = remoteMD5
+			op.reportDocument(report)
+			op.printDocumentLine(doc.NameLocal, "Error: unable to download %s\n%s", remoteFileURL, err)
+			return
+		}
+
+		acceptRemoteVersion(doc, remoteMD5, remoteETag, nil)
+		doc.Absent = false
+		fetched[i] = true
+		op.reportDocument(DocumentReport{Name: doc.NameLocal, State: StateVerified, LocalMD5: remoteMD5, RemoteMD5: remoteMD5,
+			ETag: remoteETag, URL: remoteFileURL})
+	})
+
+	for _, ok := range fetched {
+		if ok {
+			return collectionWrite(op, props)
+		}
+	}
+	return nil
+}
+
+// Returns an error unless the MD5 of the downloaded file matches one of the expected MD5s. The MD5s that are not
+// known are empty, and the file is not verified when neither is known.
+func verifyDownload(fileName string, md5 string, remoteMD5 string) error {
+	if len(md5) == 0 && len(remoteMD5) == 0 {
+		return nil
+	}
+
+	actualMD5, err := md5File(fileName)
+	if err != nil {
+		return err
+	}
+	if actualMD5 != md5 && actualMD5 != remoteMD5 {
+		return errors.New(fmt.Sprintf("downloaded file has MD5 %s instead of %s", actualMD5, strings.TrimSpace(md5+" "+remoteMD5)))
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// Opens the temporary file of a download and returns the offset at which the download resumes. Downloads are only
+// resumed when they can be verified.
+func openDownloadFile(tmpFileName string, resume bool, size int64) (*os.File, int64, error) {
+	if resume {
+		info, err := os.Stat(tmpFileName)
+		if err == nil && info.Size() <= size {
+			out, err := os.OpenFile(tmpFileName, os.O_WRONLY|os.O_APPEND, 0644)
+			return out, info.Size(), err
+		}
+	}
+
+	out, err := os.Create(tmpFileName)
+	return out, 0, err
+}
+
+// Downloads a remote document from the backend to a local file while reporting progress. The download is written
+// to <localName>.tmp, which is kept if the download fails so that the next attempt resumes it, and it is only
+// renamed to localName once it matches md5 or the pitmd5 metadata of the remote document.
+func downloadDocument(op *operation, backend Backend, remoteName string, localName string, md5 string) error {
+	err := checkDocumentName(localName)
+	if err != nil {
+		return err
+	}
+
+	remoteDoc, err := backend.Stat(op.ctx, remoteName)
+	if err != nil {
+		return err
+	}
+
+	// Documents in folders (e.g. "clips/intro.mp4") are downloaded into the same folders.
+	err = os.MkdirAll(filepath.Dir(op.path(localName)), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	tmpFileName := op.path(localName) + ".tmp"
+	verifiable := len(md5) != 0 || len(remoteDoc.MD5) != 0
+	for {
+		out, offset, err := openDownloadFile(tmpFileName, verifiable, remoteDoc.Size)
+		if err != nil {
+			return err
+		}
+
+		counter := &WriteCounter{Name: localName, Total: uint64(offset), op: op}
+		op.beginDownload()
+		if offset < remoteDoc.Size || remoteDoc.Size == 0 {
+			err = backend.Get(op.ctx, remoteName, offset, io.MultiWriter(out, counter))
+		}
+		out.Close()
+		op.endDownload(localName, err)
+		if err != nil {
+			if !verifiable {
+				deleteFile(tmpFileName)
+			}
+			return err
+		}
+
+		err = verifyDownload(tmpFileName, md5, remoteDoc.MD5)
+		if err != nil {
+			deleteFile(tmpFileName)
+			if offset > 0 {
+				// The partial download may have been of another version, so download the whole document again.
+				continue
+			}
+			return err
+		}
+
+		return os.Rename(tmpFileName, op.path(localName))
+	}
+}
+
+// The code below was initially taken from "https://golangcode.com/download-a-file-with-progress/"
+
+// WriteCounter counts the number of bytes written to it. It implements to the io.Writer interface
+// and we can pass this into io.TeeReader() which will report progress on each write cycle.
+type WriteCounter struct {
+	Name  string
+	Total uint64
+
+	op *operation // Prints the progress.
+}
+
+func (wc *WriteCounter) Write(p []byte) (int, error) {
+	n := len(p)
+	wc.Total += uint64(n)
+	wc.PrintProgress()
+	return n, nil
+}
+
+func (wc WriteCounter) PrintProgress() {
+	// Return again and print current status of download
+	if wc.op != nil {
+		wc.op.printDownloadProgress(wc.Name, wc.Total)
+	}
+}
@@ -0,0 +1,243 @@
+package pit
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Pushes a collection from computer1 and changes the working directory to computer2.
+func setupClone(t *testing.T, te *testEnvironment) collectionProperties {
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "intro")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	props := readTestCollection(t)
+	te.chdirComputer("computer2")
+	return props
+}
+
+func TestCloneByRemoteNameIntoDirectory(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupClone(t, te)
+
+	captureOutput(t, func() { collectionClone(te.op, props.NameRemote, filepath.Join("review", "videos"), CloneOptions{}) })
+	te.chdirCloned()
+	if readTestFile(t, "intro.mp4") != "intro" {
+		t.Errorf("intro.mp4 was not cloned")
+	}
+	if !strings.HasSuffix(filepath.ToSlash(te.op.dir), "computer2/review/videos") {
+		t.Errorf("cloned into unexpected directory %s", te.op.dir)
+	}
+
+	cloned := readTestCollection(t)
+	if cloned.NameRemote != props.NameRemote || cloned.URL != te.server.endpoint()+"/"+testContainerName {
+		t.Errorf("unexpected cloned collection %+v", cloned)
+	}
+
+	// The collection is registered once in account.json.
+	account := new(accountProperties)
+	if err := account.read(); err != nil {
+		t.Fatal(err)
+	}
+	registered := 0
+	for _, col := range account.Collections {
+		if col.NameRemote == props.NameRemote {
+			registered++
+		}
+	}
+	if registered != 1 {
+		t.Errorf("collection registered %d times: %+v", registered, account.Collections)
+	}
+}
+
+func TestCloneByManifestURL(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupClone(t, te)
+	manifestURL := te.backend(t).URL(props.NameRemote + ".json")
+
+	captureOutput(t, func() { collectionClone(te.op, manifestURL, "shared", CloneOptions{}) })
+	te.chdirCloned()
+	if readTestFile(t, "intro.mp4") != "intro" {
+		t.Errorf("intro.mp4 was not cloned")
+	}
+
+	// The clone is bound to the Container in account.json so changes can be pushed.
+	writeTestFile(t, "intro.mp4", "intro from computer2")
+	collectionAdd(te.op, "intro.mp4")
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	if blob := te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4")); string(blob.data) != "intro from computer2" {
+		t.Errorf("push from the clone failed:\n%s", output)
+	}
+}
+
+func TestCloneByManifestURLOfUnknownContainer(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupClone(t, te)
+	manifestURL := te.backend(t).URL(props.NameRemote + ".json")
+
+	// A colleague without the Container in account.json clones from a public container.
+	account := new(accountProperties)
+	if err := account.read(); err != nil {
+		t.Fatal(err)
+	}
+	account.Containers = nil
+	account.Collections = nil
+	if err := account.write(); err != nil {
+		t.Fatal(err)
+	}
+	te.server.allowAnonymousRead()
+
+	captureOutput(t, func() { collectionClone(te.op, manifestURL, "", CloneOptions{}) })
+	te.chdirCloned()
+	if readTestFile(t, "intro.mp4") != "intro" {
+		t.Errorf("intro.mp4 was not cloned")
+	}
+	if _, ok, _ := account.findCollection(props.NameRemote); !ok {
+		t.Errorf("cloned collection was not registered")
+	}
+
+	output := captureOutput(t, func() { collectionPull(te.op, false) })
+	assertContains(t, output, "intro.mp4            up to date")
+
+	writeTestFile(t, "intro.mp4", "intro from computer2")
+	collectionAdd(te.op, "intro.mp4")
+	output = captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	assertContains(t, output, "Container is read-only")
+}
+
+func TestParseManifestURL(t *testing.T) {
+	containerURL, nameRemote, err := parseManifestURL("https://pithub.blob.core.windows.net/nvm4zqwm/abcdefgh.json")
+	if err != nil || containerURL != "https://pithub.blob.core.windows.net/nvm4zqwm" || nameRemote != "abcdefgh" {
+		t.Errorf("unexpected result %s %s %v", containerURL, nameRemote, err)
+	}
+
+	if _, _, err := parseManifestURL("https://pithub.blob.core.windows.net/nvm4zqwm/abcdefgh-intro.mp4"); err == nil {
+		t.Errorf("URL of a document was accepted as a manifest URL")
+	}
+}
+
+func TestSparseCloneAndFetch(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	for _, name := range []string{"intro.mp4", "outro.mp4", "notes.txt"} {
+		writeTestFile(t, name, "content of "+name)
+		collectionAdd(te.op, name)
+	}
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	props := readTestCollection(t)
+	te.chdirComputer("computer2")
+
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{Only: []string{"intro.*", "*.txt"}}) })
+	te.chdirCloned()
+	if fileExists("outro.mp4") || !fileExists("intro.mp4") || !fileExists("notes.txt") {
+		t.Fatalf("unexpected documents were cloned")
+	}
+	for _, doc := range readTestCollection(t).Documents {
+		if doc.Absent != (doc.NameLocal == "outro.mp4") {
+			t.Errorf("unexpected absent state of %s", doc.NameLocal)
+		}
+	}
+
+	output := captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "outro.mp4            remote only")
+	if strings.Contains(output, "Document has been deleted") {
+		t.Errorf("absent document reported as deleted:\n%s", output)
+	}
+
+	// Pushing a sparse clone keeps the documents that were not downloaded.
+	output = captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	if strings.Contains(output, "Error") {
+		t.Errorf("push of a sparse clone failed:\n%s", output)
+	}
+	if te.server.blob(testContainerName, getRemoteFileName(props, "outro.mp4")) == nil {
+		t.Errorf("outro.mp4 was removed from the container")
+	}
+
+	captureOutput(t, func() { collectionFetch(te.op, []string{"outro.mp4"}, 1) })
+	if readTestFile(t, "outro.mp4") != "content of outro.mp4" {
+		t.Errorf("outro.mp4 was not fetched")
+	}
+	for _, doc := range readTestCollection(t).Documents {
+		if doc.Absent {
+			t.Errorf("%s is still absent", doc.NameLocal)
+		}
+	}
+	assertContains(t, captureOutput(t, func() { collectionStatus(te.op) }), "outro.mp4            verified")
+
+	output = captureOutput(t, func() { collectionFetch(te.op, []string{"missing.mp4"}, 1) })
+	assertContains(t, output, "missing.mp4          Error: not in the collection")
+}
+
+func TestCloneRejectsDocumentNamesOutsideTheCollection(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupClone(t, te)
+	backend := te.backend(t)
+	ctx := context.Background()
+
+	// A remote collection names a document outside the folder it is cloned into.
+	source := filepath.Join(te.root, "escaped")
+	writeTestFile(t, source, "escaped")
+	if _, err := backend.Put(ctx, source, getRemoteFileName(props, "../../escaped.txt"), accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+	props.Documents = append(props.Documents, documentProperties{NameLocal: "../../escaped.txt", MD5: md5String("escaped")})
+	data, err := json.Marshal(props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(te.root, "manifest.json")
+	if err := ioutil.WriteFile(manifest, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Put(ctx, manifest, props.NameRemote+".json", accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	captureOutput(t, func() { err = collectionClone(te.op, props.NameRemote, "", CloneOptions{}) })
+	if err == nil || !strings.Contains(err.Error(), "Invalid document name") {
+		t.Errorf("expected an invalid document name error, got %v", err)
+	}
+	if fileExists(filepath.Join(te.root, "escaped.txt")) {
+		t.Errorf("a document was written outside the collection folder")
+	}
+}
+
+func TestCheckDocumentName(t *testing.T) {
+	for _, name := range []string{"intro.mp4", "clips/intro.mp4", "a..b.mp4"} {
+		if err := checkDocumentName(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "/etc/passwd", "../intro.mp4", "clips/../../intro.mp4", "clips\\..\\..\\intro.mp4",
+		"./intro.mp4", "clips//intro.mp4"} {
+		if err := checkDocumentName(name); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+}
+
+func TestCloneFolderName(t *testing.T) {
+	tests := []struct {
+		nameLocal string
+		folder    string
+	}{
+		{"videos", "videos"},
+		{"../../videos", "videos"},
+		{"/tmp/videos/", "videos"},
+		{"..", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		folder, err := cloneFolderName(test.nameLocal)
+		if folder != test.folder || (err != nil) != (len(test.folder) == 0) {
+			t.Errorf("%q: unexpected folder %q, %v", test.nameLocal, folder, err)
+		}
+	}
+}
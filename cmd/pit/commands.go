@@ -0,0 +1,462 @@
+/*
+	The command tree of pit. Each command has its own flags, the number of arguments it accepts, and a summary that
+	is used for the usage text, so adding a command to commandTree is all that is needed to document it.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/PitHubInc/pit"
+)
+
+type command struct {
+	name    string
+	args    string // Example: "<document-name>...", shown in the usage text.
+	summary string
+	minArgs int
+	maxArgs int                    // -1 if any number of arguments is accepted.
+	flags   func(fs *flag.FlagSet) // Adds the flags of the command, nil if it has none.
+	action  func(args []string) error
+	hidden  bool // Not listed in the usage text.
+	rawArgs bool // The flags are not parsed and are passed to action with the arguments.
+	json    bool // Prints its output as JSON with "--json", which is a usage error for other commands.
+
+	subcommands []*command // Example: "profile add", the name includes the name of the parent command.
+}
+
+var commandTree []*command
+
+func init() {
+	commandTree = []*command{
+		initCommand(),
+		addCommand(),
+		moveCommand(),
+		removeCommand(),
+		pushCommand(),
+		pullCommand(),
+		cloneCommand(),
+		fetchCommand(),
+		statusCommand(),
+		profileCommand(),
+		remoteCommand(),
+		credentialCommand(),
+		completionCommand(),
+		versionCommand(),
+		helpCommand(),
+		completeCommand(),
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commandTree {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func (cmd *command) flagSet() *flag.FlagSet {
+	fs := newFlagSet(cmd.name)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	addGlobalFlags(fs)
+	return fs
+}
+
+// Returns the subcommand named by the first argument and the arguments that follow it.
+func (cmd *command) subcommand(args []string) (*command, []string, error) {
+	if len(args) == 0 {
+		return nil, nil, &usageError{command: cmd, message: fmt.Sprintf("'%s' must include a subcommand", cmd.name)}
+	} else if args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		return nil, nil, errHelp
+	}
+
+	for _, sub := range cmd.subcommands {
+		if sub.name == cmd.name+" "+args[0] {
+			return sub, args[1:], nil
+		}
+	}
+	return nil, nil, &usageError{command: cmd, message: fmt.Sprintf("unknown subcommand \"%s %s\"", cmd.name, args[0])}
+}
+
+// Parses the flags of the command and returns its arguments.
+func (cmd *command) parse(args []string) ([]string, error) {
+	if cmd.rawArgs {
+		return args, nil
+	}
+
+	args, err := parseFlags(cmd.flagSet(), args)
+	if err == errHelp {
+		return nil, err
+	} else if err != nil {
+		return nil, &usageError{command: cmd, message: err.Error()}
+	}
+
+	if global.json && !cmd.json {
+		return nil, &usageError{command: cmd, message: fmt.Sprintf("'%s' does not support --json", cmd.name)}
+	} else if len(args) < cmd.minArgs {
+		return nil, &usageError{command: cmd, message: fmt.Sprintf("'%s' must include %s", cmd.name, cmd.args)}
+	} else if cmd.maxArgs >= 0 && len(args) > cmd.maxArgs {
+		return nil, &usageError{command: cmd, message: fmt.Sprintf("too many arguments for '%s'", cmd.name)}
+	}
+	return args, nil
+}
+
+func (cmd *command) run(args []string) error {
+	return cmd.action(args)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, `Usage: pit [global flags] <command> [flags] [arguments]
+
+The Pit application provides simple functionality that allows
+you to share your videos online.
+
+Commands:
+`)
+	for _, cmd := range commandTree {
+		if !cmd.hidden {
+			fmt.Fprintf(w, "    %s  %s\n", padRight(cmd.name, 13), cmd.summary)
+		}
+	}
+
+	fmt.Fprintf(w, "\nGlobal Flags:\n")
+	printFlags(w, helpCommand().flagSet(), true)
+
+	fmt.Fprintf(w, `
+Exit Codes:
+    0  Success
+    1  Failure, e.g. a document could not be pushed or downloaded
+    2  Unknown command or invalid arguments
+    3  Remote document or collection not found
+    4  Changed by another computer, use "pit pull" and push again
+    5  Missing or rejected credentials
+    6  Container not found
+    7  Too many requests, try again later
+
+Use "pit help <command>" for the flags of a command.
+`)
+}
+
+func printCommandUsage(w io.Writer, cmd *command) {
+	usage := "pit " + cmd.name
+	fs := cmd.flagSet()
+	hasFlags := false
+	fs.VisitAll(func(f *flag.Flag) { hasFlags = hasFlags || !isGlobalFlag(f.Name) })
+	if hasFlags {
+		usage += " [flags]"
+	}
+	if len(cmd.args) != 0 {
+		usage += " " + cmd.args
+	}
+
+	if len(cmd.subcommands) != 0 {
+		fmt.Fprintf(w, "Usage: pit %s <subcommand> [flags] [arguments]\n\n%s\n\nSubcommands:\n", cmd.name, cmd.summary)
+		for _, sub := range cmd.subcommands {
+			fmt.Fprintf(w, "    %s  %s\n", padRight(sub.name, 13), sub.summary)
+		}
+		fmt.Fprintf(w, "\nUse \"pit help %s <subcommand>\" for the flags of a subcommand.\n", cmd.name)
+		return
+	}
+
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", usage, cmd.summary)
+	if hasFlags {
+		fmt.Fprintf(w, "\nFlags:\n")
+		printFlags(w, fs, false)
+	}
+	fmt.Fprintf(w, "\nGlobal Flags:\n")
+	printFlags(w, fs, true)
+}
+
+// Adds "--porcelain" to commands with a report.
+func porcelainFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&porcelain, "porcelain", false, "Print one tab-separated line per document and a summary line")
+}
+
+func initCommand() *command {
+	return &command{
+		name:    "init",
+		summary: "Create a Pit collection",
+		action: func(args []string) error {
+			_, err := client.Init(ctx, ".")
+			return operationError(err)
+		},
+	}
+}
+
+func addCommand() *command {
+	var all bool
+	return &command{
+		name:    "add",
+		args:    "<document-name | folder | PATTERN>...",
+		summary: "Add or update Pit collection documents, see .pitignore to skip files",
+		maxArgs: -1,
+		flags: func(fs *flag.FlagSet) {
+			boolFlag(fs, &all, "all", "A", "Add every document in the collection folder that is not ignored")
+		},
+		action: func(args []string) error {
+			if len(args) == 0 && !all {
+				return &usageError{command: findCommand("add"), message: "'add' must include a [[document-name]] argument or --all"}
+			}
+
+			collection, err := openCollection("add")
+			if err != nil {
+				return err
+			}
+			if all {
+				err = collection.AddAll(ctx)
+			} else {
+				err = collection.Add(ctx, args...)
+			}
+			return operationError(err)
+		},
+	}
+}
+
+func moveCommand() *command {
+	var keepAlias bool
+	return &command{
+		name:    "mv",
+		args:    "<document-name> <new-document-name>",
+		summary: "Rename a document, and its online copy with the next push",
+		minArgs: 2,
+		maxArgs: 2,
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&keepAlias, "keep-alias", false, "Keep a copy of the online document at its old URL")
+		},
+		action: func(args []string) error {
+			collection, err := openCollection("mv")
+			if err != nil {
+				return err
+			}
+			return operationError(collection.Move(ctx, args[0], args[1], keepAlias))
+		},
+	}
+}
+
+func removeCommand() *command {
+	var options pit.RemoveOptions
+	return &command{
+		name:    "rm",
+		args:    "<document-name>...",
+		summary: "Remove a document from the collection, and from online with the next push",
+		minArgs: 1,
+		maxArgs: -1,
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&options.Cached, "cached", false, "Keep the online document, only stop tracking it")
+			fs.BoolVar(&options.Archive, "archive", false, "Copy the online document to the archive before deleting it")
+		},
+		action: func(args []string) error {
+			if options.Cached && options.Archive {
+				return &usageError{command: findCommand("rm"), message: "only one of --cached or --archive can be used"}
+			}
+
+			collection, err := openCollection("rm")
+			if err != nil {
+				return err
+			}
+			var lastErr error
+			for _, documentName := range args {
+				err := collection.Remove(ctx, documentName, options)
+				if err != nil {
+					lastErr = operationError(err)
+				}
+			}
+			return lastErr
+		},
+	}
+}
+
+func pushCommand() *command {
+	var force, keepBoth, theirs bool
+	var jobs jobsValue
+	return &command{
+		name:    "push",
+		summary: "Copy all new or updated documents so they can be viewed online",
+		json:    true,
+		flags: func(fs *flag.FlagSet) {
+			boolFlag(fs, &force, "force", "f", "Overwrite documents that were changed by another computer")
+			fs.BoolVar(&keepBoth, "keep-both", false, "Push documents changed by another computer under a new name")
+			fs.BoolVar(&theirs, "theirs", false, "Keep the documents changed by another computer")
+			fs.Var(&jobs, "jobs", "Transfer `N` documents at the same time (default 4)")
+			porcelainFlag(fs)
+		},
+		action: func(args []string) error {
+			var options pit.PushOptions
+			resolutions := 0
+			if force {
+				options.Resolution = pit.ConflictForce
+				resolutions++
+			}
+			if keepBoth {
+				options.Resolution = pit.ConflictKeepBoth
+				resolutions++
+			}
+			if theirs {
+				options.Resolution = pit.ConflictTheirs
+				resolutions++
+			}
+
+			if resolutions > 1 {
+				return &usageError{command: findCommand("push"), message: "only one of --force, --keep-both, or --theirs can be used"}
+			}
+			options.Jobs = int(jobs)
+
+			collection, err := openCollection("push")
+			if err != nil {
+				return err
+			}
+			return writeReport(collection.Push(ctx, options))
+		},
+	}
+}
+
+func pullCommand() *command {
+	var force bool
+	return &command{
+		name:    "pull",
+		summary: "Copy documents updated on another computer into the collection",
+		json:    true,
+		flags: func(fs *flag.FlagSet) {
+			boolFlag(fs, &force, "force", "f", "Overwrite local changes")
+			porcelainFlag(fs)
+		},
+		action: func(args []string) error {
+			collection, err := openCollection("pull")
+			if err != nil {
+				return err
+			}
+			return writeReport(collection.Pull(ctx, force))
+		},
+	}
+}
+
+func cloneCommand() *command {
+	var only stringList
+	var jobs jobsValue
+	return &command{
+		name:    "clone",
+		args:    "<collection-name | remote-name | manifest-URL> [directory]",
+		summary: "Copy a collection, or only some of its documents, to this computer",
+		json:    true,
+		minArgs: 1,
+		maxArgs: 2,
+		flags: func(fs *flag.FlagSet) {
+			fs.Var(&only, "only", "Only copy the documents that match `PATTERN`, can be repeated")
+			fs.Var(&jobs, "jobs", "Transfer `N` documents at the same time (default 4)")
+			porcelainFlag(fs)
+		},
+		action: func(args []string) error {
+			dir := ""
+			if len(args) > 1 {
+				dir = args[1]
+			}
+			err := warnProduction()
+			if err != nil {
+				return err
+			}
+			options := pit.CloneOptions{Jobs: int(jobs), Only: only}
+			_, report, err := client.Clone(ctx, args[0], dir, options)
+			return writeReport(report, err)
+		},
+	}
+}
+
+func fetchCommand() *command {
+	var jobs jobsValue
+	return &command{
+		name:    "fetch",
+		args:    "<document-name | PATTERN>...",
+		summary: "Copy a document that was not copied by \"pit clone --only\"",
+		json:    true,
+		minArgs: 1,
+		maxArgs: -1,
+		flags: func(fs *flag.FlagSet) {
+			fs.Var(&jobs, "jobs", "Transfer `N` documents at the same time (default 4)")
+			porcelainFlag(fs)
+		},
+		action: func(args []string) error {
+			collection, err := openCollection("fetch")
+			if err != nil {
+				return err
+			}
+			return writeReport(collection.Fetch(ctx, args, int(jobs)))
+		},
+	}
+}
+
+func statusCommand() *command {
+	return &command{
+		name:    "status",
+		summary: "View the current status of the collection including document URLs",
+		json:    true,
+		flags:   porcelainFlag,
+		action: func(args []string) error {
+			collection, err := openCollection("status")
+			if err != nil {
+				return err
+			}
+			return writeReport(collection.Status(ctx))
+		},
+	}
+}
+
+func versionCommand() *command {
+	return &command{
+		name:    "version",
+		summary: "View the version of pit",
+		json:    true,
+		action: func(args []string) error {
+			v := fmt.Sprintf("version/build: %s", pit.Version())
+			if global.json {
+				err := writeJSON(struct {
+					Version string `json:"version"`
+				}{pit.Version()})
+				if err != nil {
+					return err
+				}
+			} else {
+				fmt.Printf(v + "\n")
+			}
+			log.Println(v + " reported")
+			return nil
+		},
+	}
+}
+
+func helpCommand() *command {
+	return &command{
+		name:    "help",
+		args:    "[command] [subcommand]",
+		summary: "View the commands, or the flags of a command",
+		maxArgs: 2,
+		action: func(args []string) error {
+			if len(args) == 0 {
+				printUsage(os.Stdout)
+				return nil
+			}
+
+			cmd := findCommand(args[0])
+			if cmd == nil {
+				return &usageError{message: fmt.Sprintf("unknown command \"%s\"", strings.TrimSpace(args[0]))}
+			}
+			if len(args) > 1 && len(cmd.subcommands) != 0 {
+				sub, _, err := cmd.subcommand(args[1:])
+				if err != nil {
+					return err
+				}
+				cmd = sub
+			}
+			printCommandUsage(os.Stdout, cmd)
+			return nil
+		},
+	}
+}
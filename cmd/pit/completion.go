@@ -0,0 +1,283 @@
+/*
+	Shell completion. "pit completion bash|zsh|fish" prints a script that calls "pit __complete <words>" whenever
+	the user presses tab. The words are the words of the command line after "pit", the last of which is the word
+	being completed. Document names come from the .pit.json of the collection, collection names for "pit clone" from
+	the account, and profile names from the profiles of the account.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const bashCompletion = `# bash completion for pit, e.g. add the following line to ~/.bashrc:
+#     source <(pit completion bash)
+_pit_complete() {
+    local IFS=$'\n'
+    COMPREPLY=($(pit __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == */ ]]; then
+        compopt -o nospace
+    fi
+}
+complete -F _pit_complete pit
+`
+
+const zshCompletion = `#compdef pit
+# zsh completion for pit, e.g. add the following line to ~/.zshrc after compinit:
+#     source <(pit completion zsh)
+_pit() {
+    local -a candidates
+    candidates=("${(@f)$(pit __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -Q -- "${(@)candidates:#*/}"
+    compadd -Q -S '' -- "${(@M)candidates:#*/}"
+}
+if [ "$funcstack[1]" = "_pit" ]; then
+    _pit "$@"
+else
+    compdef _pit pit
+fi
+`
+
+const fishCompletion = `# fish completion for pit, e.g.:
+#     pit completion fish > ~/.config/fish/completions/pit.fish
+function __pit_complete
+    set -l words (commandline -opc)
+    set -e words[1]
+    pit __complete $words (commandline -ct) 2>/dev/null
+end
+complete -c pit -f -a '(__pit_complete)'
+`
+
+func completionCommand() *command {
+	return &command{
+		name:    "completion",
+		args:    "<bash | zsh | fish>",
+		summary: "Print the shell script that completes commands, documents, and collections",
+		minArgs: 1,
+		maxArgs: 1,
+		action: func(args []string) error {
+			scripts := map[string]string{"bash": bashCompletion, "zsh": zshCompletion, "fish": fishCompletion}
+			script, ok := scripts[args[0]]
+			if !ok {
+				return &usageError{command: findCommand("completion"), message: fmt.Sprintf("unknown shell \"%s\"", args[0])}
+			}
+			fmt.Print(script)
+			return nil
+		},
+	}
+}
+
+// Called by the completion scripts. The arguments are not parsed because they are usually incomplete.
+func completeCommand() *command {
+	return &command{
+		name:    "__complete",
+		hidden:  true,
+		rawArgs: true,
+		maxArgs: -1,
+		action: func(args []string) error {
+			client.Verbose = false
+			for _, candidate := range complete(args) {
+				fmt.Println(candidate)
+			}
+			return nil
+		},
+	}
+}
+
+// Returns the candidates for the last word of the command line.
+func complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	word := words[len(words)-1]
+	words = words[:len(words)-1]
+
+	// Find the command, skipping the global flags and their values.
+	var cmd *command
+	var args []string
+	dir := "."
+	previous := ""
+	for i, w := range words {
+		if previous == "--dir" {
+			dir = w
+		}
+		if cmd != nil && len(cmd.subcommands) != 0 && !strings.HasPrefix(w, "-") {
+			cmd, _, _ = cmd.subcommand([]string{w})
+			if cmd == nil {
+				return nil
+			}
+		} else if cmd != nil {
+			args = append(args, w)
+		} else if !strings.HasPrefix(w, "-") && !takesValue(nil, previous) {
+			cmd = findCommand(w)
+			if cmd == nil {
+				return nil
+			}
+		}
+		previous = words[i]
+	}
+
+	if cmd == nil && strings.HasPrefix(word, "-") {
+		return matching(flagNames(nil), word)
+	} else if takesValue(cmd, previous) {
+		return completeFlagValue(strings.TrimLeft(previous, "-"), word, dir)
+	} else if cmd == nil {
+		var names []string
+		for _, c := range commandTree {
+			if !c.hidden {
+				names = append(names, c.name)
+			}
+		}
+		return matching(names, word)
+	} else if strings.HasPrefix(word, "-") {
+		return matching(flagNames(cmd), word)
+	} else if len(cmd.subcommands) != 0 {
+		var names []string
+		for _, sub := range cmd.subcommands {
+			names = append(names, strings.TrimPrefix(sub.name, cmd.name+" "))
+		}
+		return matching(names, word)
+	}
+
+	// The position of the word among the arguments of the command, e.g. 1 for the new name of "pit mv".
+	position := 0
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") && (i == 0 || !takesValue(cmd, args[i-1])) {
+			position++
+		}
+	}
+
+	switch {
+	case cmd.name == "add":
+		return completeFiles(dir, word)
+	case cmd.name == "rm" || cmd.name == "fetch" || (cmd.name == "mv" && position == 0):
+		return matching(documentNames(dir), word)
+	case cmd.name == "clone" && position == 0:
+		names, _ := client.CollectionNames()
+		return matching(names, word)
+	case cmd.name == "help" && position == 0:
+		return complete([]string{word})
+	case (cmd.name == "profile use" || cmd.name == "profile rm" || cmd.name == "remote set" ||
+		cmd.name == "credential set" || cmd.name == "credential rm") && position == 0:
+		return matching(profileNames(), word)
+	case cmd.name == "completion" && position == 0:
+		return matching([]string{"bash", "fish", "zsh"}, word)
+	}
+	return nil
+}
+
+// Returns the flags of the command, or the global flags if cmd is nil, e.g. "--jobs".
+func flagNames(cmd *command) []string {
+	fs := newFlagSet("pit")
+	if cmd != nil {
+		fs = cmd.flagSet()
+	} else {
+		addGlobalFlags(fs)
+	}
+
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if !isShortFlagName(f.Name) {
+			names = append(names, "--"+f.Name)
+		}
+	})
+	return names
+}
+
+// Returns true if the argument is a flag of the command, or a global flag, that is followed by a value.
+func takesValue(cmd *command, arg string) bool {
+	if !strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+		return false
+	}
+
+	fs := newFlagSet("pit")
+	if cmd != nil {
+		fs = cmd.flagSet()
+	} else {
+		addGlobalFlags(fs)
+	}
+	f := fs.Lookup(strings.TrimLeft(arg, "-"))
+	if f == nil {
+		return false
+	}
+	_, isBool := f.Value.(interface{ IsBoolFlag() bool })
+	return !isBool
+}
+
+func completeFlagValue(name string, word string, dir string) []string {
+	switch name {
+	case "profile":
+		return matching(profileNames(), word)
+	case "only":
+		return matching(documentNames(dir), word)
+	case "dir":
+		var dirs []string
+		for _, candidate := range completeFiles(".", word) {
+			if strings.HasSuffix(candidate, "/") {
+				dirs = append(dirs, candidate)
+			}
+		}
+		return dirs
+	}
+	return nil
+}
+
+func profileNames() []string {
+	profiles, _ := client.Profiles()
+	var names []string
+	for _, profile := range profiles {
+		names = append(names, profile.Name)
+	}
+	return names
+}
+
+// Returns the names of the documents of the collection in dir.
+func documentNames(dir string) []string {
+	collection, err := client.OpenCollection(dir)
+	if err != nil {
+		return nil
+	}
+	names, _ := collection.DocumentNames(ctx)
+	return names
+}
+
+// Returns the files and folders, with a trailing "/", that start with word. Hidden files are only returned if word
+// names them, e.g. ".pitignore".
+func completeFiles(dir string, word string) []string {
+	folder, prefix := filepath.Split(filepath.FromSlash(word))
+	entries, err := ioutil.ReadDir(filepath.Join(dir, folder))
+	if err != nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		candidate := filepath.ToSlash(filepath.Join(folder, name))
+		if entry.IsDir() {
+			candidate += "/"
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// Returns the sorted candidates that start with word.
+func matching(candidates []string, word string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/PitHubInc/pit"
+)
+
+func TestComplete(t *testing.T) {
+	t.Setenv("PIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	var err error
+	client, err = pit.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection, err := client.Init(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"intro.mp4", "clips/outro.mp4", "notes.txt"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := collection.Add(ctx, "intro.mp4", "clips/outro.mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		words    []string
+		expected []string
+	}{
+		{[]string{"pu"}, []string{"pull", "push"}},
+		{[]string{"push", "--j"}, []string{"--jobs", "--json"}},
+		{[]string{"--dir", dir, "rm", ""}, []string{"clips/outro.mp4", "intro.mp4"}},
+		{[]string{"--dir", dir, "mv", "cl"}, []string{"clips/outro.mp4"}},
+		{[]string{"--dir", dir, "mv", "intro.mp4", ""}, nil},
+		{[]string{"--dir", dir, "add", "n"}, []string{"notes.txt"}},
+		{[]string{"--dir", dir, "add", "clips/"}, []string{"clips/outro.mp4"}},
+		{[]string{"--dir", dir, "clone", "--only", "i"}, []string{"intro.mp4"}},
+		{[]string{"completion", "f"}, []string{"fish"}},
+		{[]string{"help", "__"}, nil},
+	}
+	for _, test := range tests {
+		if candidates := complete(test.words); !reflect.DeepEqual(candidates, test.expected) {
+			t.Errorf("complete(%q) = %q, expected %q", test.words, candidates, test.expected)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/*
+	The "pit credential" commands store the keys of the profiles in the encrypted credential store, see
+	credential.go of the pit package. Keys and passphrases are read from the terminal without echo, or from stdin
+	when it is not a terminal, so that they do not end up in the shell history.
+*/
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func credentialCommand() *command {
+	return &command{
+		name:    "credential",
+		summary: "Store or remove the key of a profile in the encrypted credential store",
+		subcommands: []*command{
+			credentialSetCommand(),
+			credentialRemoveCommand(),
+		},
+	}
+}
+
+func credentialSetCommand() *command {
+	return &command{
+		name:    "credential set",
+		args:    "<profile>",
+		summary: "Store the key of the profile, the key and the passphrase are read from the terminal",
+		minArgs: 1,
+		maxArgs: 1,
+		action: func(args []string) error {
+			key, err := readSecret("Key: ")
+			if err != nil {
+				return err
+			}
+
+			err = client.SetCredential(args[0], key)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Stored the key of profile \"%s\"\n", args[0])
+			return nil
+		},
+	}
+}
+
+func credentialRemoveCommand() *command {
+	return &command{
+		name:    "credential rm",
+		args:    "<profile>",
+		summary: "Remove the key of the profile",
+		minArgs: 1,
+		maxArgs: 1,
+		action: func(args []string) error {
+			err := client.RemoveCredential(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed the key of profile \"%s\"\n", args[0])
+			return nil
+		},
+	}
+}
+
+// Reads a line from the terminal without echo, or from stdin if it is not a terminal. Returns an empty string if
+// there is no input.
+func readSecret(prompt string) (string, error) {
+	info, err := os.Stdin.Stat()
+	terminal := err == nil && info.Mode()&os.ModeCharDevice != 0
+	if terminal {
+		fmt.Fprint(os.Stderr, prompt)
+		echoOff := stty("-echo") == nil
+		defer func() {
+			if echoOff {
+				stty("echo")
+			}
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err == io.EOF {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// Todo: Disable the echo on Windows, where stty is not available and the secret is echoed.
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
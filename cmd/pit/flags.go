@@ -0,0 +1,169 @@
+/*
+	Flags are parsed with the flag package. Every command has its own FlagSet that also includes the global flags,
+	so that global flags can be given before or after the command, e.g. "pit --json status" or "pit status --json".
+	Flags and arguments can be mixed, e.g. "pit add intro.mp4 --all".
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/PitHubInc/pit"
+)
+
+// The errHelp error is returned when "--help" or "-h" is given.
+var errHelp = flag.ErrHelp
+
+// Short names of flags, e.g. "f" for "force". They are listed with the long name in the usage text.
+var shortFlagNames = map[string]string{}
+
+// Adds the global flags to the FlagSet. The values given before the command are kept.
+func addGlobalFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&global.verbose, "verbose", global.verbose, "Also write log messages to stderr")
+	fs.BoolVar(&global.quiet, "quiet", global.quiet, "Do not print the output of the command, only errors")
+	fs.BoolVar(&global.json, "json", global.json, "Print the report, remote, profiles, or version as JSON")
+	fs.StringVar(&global.dir, "dir", global.dir, "Run as if pit was started in `folder`")
+	fs.StringVar(&global.profile, "profile", global.profile, "Use the account profile `name`")
+}
+
+var globalFlagNames = []string{"verbose", "quiet", "json", "dir", "profile"}
+
+func isGlobalFlag(name string) bool {
+	for _, globalName := range globalFlagNames {
+		if globalName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds a bool flag with a short name, e.g. "--force" and "-f".
+func boolFlag(fs *flag.FlagSet, p *bool, name string, short string, usage string) {
+	fs.BoolVar(p, name, false, usage)
+	if len(short) != 0 {
+		fs.BoolVar(p, short, false, usage)
+		shortFlagNames[name] = short
+	}
+}
+
+// The value of "--jobs", the number of documents to transfer at the same time.
+type jobsValue int
+
+func (jv *jobsValue) String() string {
+	return fmt.Sprint(int(*jv))
+}
+
+func (jv *jobsValue) Set(value string) error {
+	jobs, err := pit.ParseJobs(value)
+	*jv = jobsValue(jobs)
+	return err
+}
+
+// The value of a flag that can be repeated, e.g. "--only '*.mp4' --only notes.pdf".
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+// Returns a FlagSet that does not print errors or usage text, which are printed by exitCode() instead.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Usage = func() {}
+	return fs
+}
+
+// Parses the global flags before the command. Returns the name of the command and the arguments that follow it.
+func parseGlobalFlags(args []string) (string, []string, error) {
+	fs := newFlagSet("pit")
+	addGlobalFlags(fs)
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return "help", nil, nil
+	} else if err != nil {
+		return "", nil, &usageError{message: err.Error()}
+	} else if fs.NArg() == 0 {
+		return "", nil, nil
+	}
+	return fs.Arg(0), fs.Args()[1:], nil
+}
+
+// Parses the flags, which can be mixed with the arguments, and returns the arguments. Arguments after "--" are not
+// parsed, e.g. "pit rm -- -intro.mp4".
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		// The flag package stops at the first argument, or after "--".
+		parsed := args[:len(args)-fs.NArg()]
+		args = fs.Args()
+		if len(parsed) != 0 && parsed[len(parsed)-1] == "--" {
+			return append(positional, args...), nil
+		} else if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Prints the flags of the FlagSet, e.g. "    --jobs N    Transfer N documents at the same time".
+func printFlags(w io.Writer, fs *flag.FlagSet, global bool) {
+	var lines [][2]string
+	width := 0
+	fs.VisitAll(func(f *flag.Flag) {
+		if isGlobalFlag(f.Name) != global || isShortFlagName(f.Name) {
+			return
+		}
+
+		name := "--" + f.Name
+		if short, ok := shortFlagNames[f.Name]; ok {
+			name += ", -" + short
+		}
+		valueName, usage := flag.UnquoteUsage(f)
+		if _, isBool := f.Value.(interface{ IsBoolFlag() bool }); !isBool && len(valueName) != 0 {
+			name += " " + strings.ToUpper(valueName)
+		}
+		if len(name) > width {
+			width = len(name)
+		}
+		lines = append(lines, [2]string{name, usage})
+	})
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i][0] < lines[j][0] })
+	for _, line := range lines {
+		fmt.Fprintf(w, "    %s  %s\n", padRight(line[0], width), line[1])
+	}
+}
+
+func isShortFlagName(name string) bool {
+	for _, short := range shortFlagNames {
+		if short == name {
+			return true
+		}
+	}
+	return false
+}
+
+func padRight(str string, length int) string {
+	for len(str) < length {
+		str += " "
+	}
+	return str
+}
@@ -0,0 +1,235 @@
+/*
+	The pit command is a thin command line interface over the pit package. The commands, their flags, and the usage
+	text are defined by the command tree in commands.go, flags are parsed with the flag package in flags.go.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"io/ioutil"
+
+	"github.com/PitHubInc/pit"
+)
+
+var client *pit.Client
+var ctx = context.Background()
+
+// Flags that can be given before or after any command.
+type globalOptions struct {
+	verbose bool   // Also write log messages to stderr.
+	quiet   bool   // Do not print the text output of the command.
+	json    bool   // Print the output of the commands that support it as JSON.
+	dir     string // Run the command in this folder instead of the working directory.
+	profile string // Use this profile instead of the active profile.
+}
+
+var global globalOptions
+
+// True if "--porcelain" was given to a command with a report.
+var porcelain bool
+
+// A usageError is returned for unknown commands and invalid arguments.
+type usageError struct {
+	command *command
+	message string
+}
+
+func (ue *usageError) Error() string {
+	return ue.message
+}
+
+// A reportedError is returned when the command failed and its output already explains why.
+type reportedError struct {
+	code int
+}
+
+func (re *reportedError) Error() string {
+	return fmt.Sprintf("exit code %d", re.code)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// Runs the command line and returns the exit code.
+func run(args []string) int {
+	log.SetOutput(ioutil.Discard) // Until start() opens the log file.
+	name, args, err := parseGlobalFlags(args)
+	if err == nil && len(name) == 0 {
+		printUsage(os.Stdout)
+		return pit.ExitSuccess
+	}
+
+	var cmd *command
+	if err == nil {
+		cmd = findCommand(name)
+		if cmd == nil {
+			err = &usageError{message: fmt.Sprintf("unknown command \"%s\"", name)}
+		}
+	}
+	for err == nil && len(cmd.subcommands) != 0 {
+		var sub *command
+		sub, args, err = cmd.subcommand(args)
+		if sub != nil {
+			cmd = sub
+		}
+	}
+	if err == nil {
+		args, err = cmd.parse(args)
+	}
+	if errors.Is(err, errHelp) {
+		printCommandUsage(os.Stdout, cmd)
+		return pit.ExitSuccess
+	}
+	if err == nil {
+		err = start()
+	}
+	if err == nil {
+		err = cmd.run(args)
+	}
+
+	code := exitCode(err)
+	if code != pit.ExitSuccess {
+		log.Println(fmt.Sprintf("Exiting with code %d", code))
+	} else {
+		log.Println("Exiting Successfully")
+	}
+	return code
+}
+
+// Opens the log file, changes to "--dir", and creates the client.
+func start() error {
+	// If the log file exists, append log messages to the file.
+	var logOutput io.Writer = ioutil.Discard
+	logFile, err := pit.OpenLogFile()
+	if err == nil {
+		logOutput = logFile
+	}
+	if global.verbose {
+		logOutput = io.MultiWriter(logOutput, os.Stderr)
+	}
+	log.SetOutput(logOutput)
+
+	if len(global.dir) != 0 {
+		err = os.Chdir(global.dir)
+		if err != nil {
+			return &usageError{message: fmt.Sprintf("unable to use --dir %s", global.dir)}
+		}
+	}
+
+	client, err = pit.NewClient()
+	if err != nil {
+		return err
+	}
+	client.Verbose = !global.quiet && !global.json && !porcelain
+	client.Profile = global.profile
+	client.Passphrase = readSecret
+	return nil
+}
+
+// Prints a warning if the profile that is about to be used is a production profile.
+func warnProduction() error {
+	profile, err := client.ActiveProfile()
+	if err != nil {
+		return err
+	}
+	if profile.Production {
+		fmt.Fprintf(os.Stderr, "Warning: **PRODUCTION** profile \"%s\" is active!\n", profile.Name)
+	}
+	return nil
+}
+
+// Prints the error, if it has not been printed already, and returns the exit code for it.
+func exitCode(err error) int {
+	var usageErr *usageError
+	var reportedErr *reportedError
+	if err == nil {
+		return pit.ExitSuccess
+	} else if errors.As(err, &usageErr) {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", usageErr.message)
+		if usageErr.command != nil {
+			printCommandUsage(os.Stderr, usageErr.command)
+		} else {
+			fmt.Fprintf(os.Stderr, "Use \"pit help\" for the list of commands.\n")
+		}
+		return pit.ExitUsage
+	} else if errors.As(err, &reportedErr) {
+		return reportedErr.code
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	return pit.ExitCode(err)
+}
+
+// Returns the collection in the working directory. If there is none, the error is reported like an error of the
+// command.
+func openCollection(command string) (*pit.Collection, error) {
+	err := warnProduction()
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := client.OpenCollection(".")
+	if err != nil {
+		report := &pit.Report{Command: command, Documents: []pit.DocumentReport{}, Summary: map[pit.DocumentState]int{},
+			Error: "No collection initialized", Failed: true}
+		if client.Verbose {
+			fmt.Printf("No collection initialized\n")
+		}
+		return nil, writeReport(report, err)
+	}
+	return collection, nil
+}
+
+// Writes the report with "--json" or "--porcelain" and returns a reportedError if the operation failed for any
+// document.
+func writeReport(report *pit.Report, err error) error {
+	if report != nil && global.json {
+		report.WriteJSON(os.Stdout)
+	} else if report != nil && porcelain {
+		report.WritePorcelain(os.Stdout)
+	} else if err != nil && !client.Verbose {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	}
+
+	if err != nil {
+		return &reportedError{pit.ExitCode(err)}
+	} else if report != nil && report.Summary[pit.StateConflict] > 0 {
+		return &reportedError{pit.ExitConflict}
+	} else if report != nil && report.Failed {
+		return &reportedError{pit.ExitFailure}
+	}
+	return nil
+}
+
+// Writes the value as indented JSON, in the same format as the reports.
+func writeJSON(value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(data))
+	return err
+}
+
+// Returns a reportedError for errors of operations, which print their own errors unless the output is discarded.
+func operationError(err error) error {
+	if err == nil {
+		return nil
+	} else if !client.Verbose {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	}
+	return &reportedError{pit.ExitCode(err)}
+}
+
+// Todo: Consider adding header:
+//     fmt.Println("\nBestOfTheBest Document Sharing")
+//     fmt.Println("\nBestOfTheBest Video Management & Sharing")
+//     fmt.Println("\nBestOfTheBest Document Management & Sharing")
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PitHubInc/pit"
+)
+
+func TestParseFlags(t *testing.T) {
+	var all bool
+	fs := newFlagSet("add")
+	boolFlag(fs, &all, "all", "A", "")
+	addGlobalFlags(fs)
+
+	args, err := parseFlags(fs, []string{"intro.mp4", "-A", "--json", "clips", "--", "--outro.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"intro.mp4", "clips", "--outro.mp4"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("parseFlags() = %v, expected %v", args, expected)
+	}
+	if !all || !global.json {
+		t.Errorf("flags after arguments were not parsed")
+	}
+	global = globalOptions{}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"help"}, pit.ExitSuccess},
+		{[]string{"help", "push"}, pit.ExitSuccess},
+		{[]string{"push", "--help"}, pit.ExitSuccess},
+		{[]string{"bogus"}, pit.ExitUsage},
+		{[]string{"-sp"}, pit.ExitUsage},
+		{[]string{"help", "bogus"}, pit.ExitUsage},
+		{[]string{"mv", "intro.mp4"}, pit.ExitUsage},
+		{[]string{"status", "intro.mp4"}, pit.ExitUsage},
+		{[]string{"push", "--jobs", "0"}, pit.ExitUsage},
+		{[]string{"push", "--bogus"}, pit.ExitUsage},
+		{[]string{"pull", "--porcelain", "--help"}, pit.ExitSuccess},
+		{[]string{"fetch", "--porcelain", "--help"}, pit.ExitSuccess},
+		{[]string{"add", "--porcelain", "intro.mp4"}, pit.ExitUsage},
+		{[]string{"add", "--json", "intro.mp4"}, pit.ExitUsage},
+		{[]string{"--json", "init"}, pit.ExitUsage},
+		{[]string{"profile", "use", "test", "--json"}, pit.ExitUsage},
+		{[]string{"push", "--json", "--help"}, pit.ExitSuccess},
+		{[]string{"version", "--json"}, pit.ExitSuccess},
+		{[]string{"--json", "profile", "list"}, pit.ExitSuccess},
+	}
+	for _, test := range tests {
+		t.Setenv("PIT_HOME", t.TempDir())
+		if code := run(test.args); code != test.code {
+			t.Errorf("pit %v exited with %d, expected %d", test.args, code, test.code)
+		}
+		global = globalOptions{}
+	}
+}
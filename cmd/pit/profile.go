@@ -0,0 +1,152 @@
+/*
+	The "pit profile" commands manage the named Containers in account.json, see profile.go of the pit package.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PitHubInc/pit"
+)
+
+func profileCommand() *command {
+	return &command{
+		name:    "profile",
+		summary: "Add, list, use, or remove the profiles of the Containers that collections are pushed to",
+		subcommands: []*command{
+			profileAddCommand(),
+			profileListCommand(),
+			profileUseCommand(),
+			profileRemoveCommand(),
+		},
+	}
+}
+
+func profileAddCommand() *command {
+	var profile pit.Profile
+	var cmd *command
+	cmd = &command{
+		name:    "profile add",
+		args:    "<name>",
+		summary: "Add a profile, the key is read from the terminal and the first profile becomes the active profile",
+		minArgs: 1,
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&profile.Type, "type", "azure", "The `type` of the Container: azure, file, or s3")
+			fs.StringVar(&profile.Container, "container", "", "The `name` of the Container, or S3 bucket")
+			fs.StringVar(&profile.Account, "account", "", "The storage account `name`, or the S3 access key ID")
+			fs.StringVar(&profile.Endpoint, "endpoint", "", "The `URL` of the blob service, e.g. of the Azurite emulator")
+			fs.StringVar(&profile.Region, "region", "", "The S3 `region`, e.g. us-west-2")
+			fs.StringVar(&profile.Path, "path", "", "The `folder` of a file Container, e.g. /Volumes/nas/pit")
+			fs.BoolVar(&profile.Production, "production", false, "Warn whenever the profile is used")
+		},
+		action: func(args []string) error {
+			profile.Name = args[0]
+			if len(profile.Container) == 0 {
+				return &usageError{command: cmd, message: "'profile add' must include --container"}
+			}
+
+			// The key is never an argument, so that it does not end up in the shell history.
+			if profile.Type != "file" {
+				var err error
+				profile.Key, err = readSecret("Key (empty to use the environment): ")
+				if err != nil {
+					return err
+				}
+			}
+
+			err := client.AddProfile(profile)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Added profile \"%s\"\n", profile.Name)
+			return nil
+		},
+	}
+	return cmd
+}
+
+func profileListCommand() *command {
+	return &command{
+		name:    "profile list",
+		summary: "List the profiles, the active profile is marked with *",
+		json:    true,
+		action: func(args []string) error {
+			profiles, err := client.Profiles()
+			if err != nil {
+				return err
+			}
+			active, err := client.ActiveProfile()
+			if err != nil {
+				return err
+			}
+
+			if global.json {
+				for i := range profiles {
+					profiles[i].Active = profiles[i].Name == active.Name
+				}
+				if profiles == nil {
+					profiles = []pit.Profile{}
+				}
+				return writeJSON(profiles)
+			}
+
+			for _, profile := range profiles {
+				marker := " "
+				if profile.Name == active.Name {
+					marker = "*"
+				}
+				production := ""
+				if profile.Production {
+					production = " (production)"
+				}
+				fmt.Printf("%s %s %s %s%s\n", marker, padRight(profile.Name, 20), padRight(profile.Type, 6),
+					profile.Container, production)
+			}
+			return nil
+		},
+	}
+}
+
+func profileUseCommand() *command {
+	return &command{
+		name:    "profile use",
+		args:    "<name>",
+		summary: "Use the profile when no profile is selected with --profile or PIT_PROFILE",
+		minArgs: 1,
+		maxArgs: 1,
+		action: func(args []string) error {
+			profile, err := client.UseProfile(args[0])
+			if err != nil {
+				return err
+			}
+
+			if profile.Production {
+				fmt.Fprintf(os.Stderr, "Warning: **PRODUCTION** profile \"%s\" enabled!\n", profile.Name)
+			} else {
+				fmt.Printf("Profile \"%s\" enabled.\n", profile.Name)
+			}
+			return nil
+		},
+	}
+}
+
+func profileRemoveCommand() *command {
+	return &command{
+		name:    "profile rm",
+		args:    "<name>",
+		summary: "Remove the profile, collections that were pushed to its Container are not changed",
+		minArgs: 1,
+		maxArgs: 1,
+		action: func(args []string) error {
+			err := client.RemoveProfile(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed profile \"%s\"\n", args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,80 @@
+/*
+	The "pit remote" commands show and change the Container that the collection is bound to, see remote.go of the
+	pit package.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func remoteCommand() *command {
+	return &command{
+		name:    "remote",
+		summary: "Show the Container the collection is pushed to, or bind the collection to another profile",
+		subcommands: []*command{
+			remoteShowCommand(),
+			remoteSetCommand(),
+		},
+	}
+}
+
+func remoteShowCommand() *command {
+	return &command{
+		name:    "remote show",
+		summary: "Show the profile and the URL of the Container the collection is bound to",
+		json:    true,
+		action: func(args []string) error {
+			collection, err := client.OpenCollection(".")
+			if err != nil {
+				return err
+			}
+
+			remote, err := collection.Remote(ctx)
+			if err != nil {
+				return operationError(err)
+			}
+
+			if global.json {
+				return writeJSON(remote)
+			} else if len(remote.URL) == 0 {
+				fmt.Printf("Collection \"%s\" is not bound to a Container, the next push binds it to the active profile\n",
+					remote.Name)
+			} else if len(remote.Profile) == 0 {
+				fmt.Printf("Collection \"%s\" bound to %s (%s)\n", remote.Name, remote.URL, remote.Type)
+			} else {
+				fmt.Printf("Collection \"%s\" bound to profile \"%s\" (%s %s)\n", remote.Name, remote.Profile,
+					remote.Type, remote.URL)
+			}
+			return nil
+		},
+	}
+}
+
+func remoteSetCommand() *command {
+	return &command{
+		name:    "remote set",
+		args:    "<profile>",
+		summary: "Bind the collection to the Container of the profile, the next push uploads every document",
+		minArgs: 1,
+		maxArgs: 1,
+		action: func(args []string) error {
+			collection, err := client.OpenCollection(".")
+			if err != nil {
+				return err
+			}
+
+			profiles, err := client.Profiles()
+			if err != nil {
+				return err
+			}
+			for _, profile := range profiles {
+				if profile.Name == args[0] && profile.Production {
+					fmt.Fprintf(os.Stderr, "Warning: **PRODUCTION** profile \"%s\" bound!\n", profile.Name)
+				}
+			}
+			return operationError(collection.SetRemote(ctx, args[0]))
+		},
+	}
+}
@@ -1,9 +1,10 @@
 /*
 Collection represents a logical grouping of Documents. Many collections can be stored in a Container.
 */
-package main
+package pit
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,16 +16,16 @@ import (
 	"time"
 )
 
-func collectionExists() bool {
+func collectionExists(op *operation) bool {
 	// Check if collection file exists.
-	info, err := os.Stat(pitFileName)
+	info, err := os.Stat(op.path(pitFileName))
 	if os.IsNotExist(err) {
 		return false
 	}
 
 	if info.IsDir() {
 		// It would be very strange that someone created a directory with the same name as our collection file.
-		fmt.Println(fmt.Sprintf("Fatal: A directory exists at %s", pitFileName))
+		fmt.Fprintln(op.out, fmt.Sprintf("Fatal: A directory exists at %s", pitFileName))
 		return false
 	}
 
@@ -32,80 +33,131 @@ func collectionExists() bool {
 }
 
 // Todo: Initialize Pit collection as .pit hidden folder. 
-func collectionInitialize() {
-	if collectionExists() {
-		fmt.Println("Collection already initialized")
+func collectionInitialize(op *operation) error {
+	if collectionExists(op) {
+		fmt.Fprintln(op.out, "Collection already initialized")
 	} else {
 		var props collectionProperties
+		var err error
 
-		props.NameLocal = collectionGetInitialLocalName()
+		props.NameLocal, err = collectionGetInitialLocalName(op)
+		if err != nil {
+			return err
+		}
 		props.NameRemote = collectionNewRemoteName()
 
 		t := time.Now()
 		props.Created = t.Format(time.RFC3339)
 		props.Updated = t.Format(time.RFC3339)
 
-		err := collectionWrite(props)
-		check(err)
-
+		// The collection is bound to the Container of the selected or active profile. If the Container is not
+		// available yet (e.g. the credentials are missing), the first push binds it.
 		userAccount := new(accountProperties)
-		userAccount.addBasicCollectionInfo(props.NameLocal, props.NameRemote)
+		container, err := selectedContainer(op.profile)
+		if err != nil {
+			return err
+		}
+		props.URL, err = container.containerURL()
+		if err == nil {
+			props.Profile = container.profileName()
+		} else {
+			log.Println(fmt.Sprintf("Collection not bound to a Container: %s", err))
+		}
+
+		err = collectionWrite(op, props)
+		if err != nil {
+			return err
+		}
+
+		err = userAccount.addBasicCollectionInfo(props.NameLocal, props.NameRemote, props.URL)
+		if err != nil {
+			return err
+		}
 
 		// Todo: Consider printing full path to be consistent with "git init".
-		fmt.Printf("Initialed empty Pit repository in %s\n", props.NameLocal)
+		fmt.Fprintf(op.out, "Initialed empty Pit repository in %s\n", props.NameLocal)
+		if len(props.Profile) != 0 {
+			fmt.Fprintf(op.out, "Bound to profile \"%s\" (%s)\n", props.Profile, props.URL)
+		}
 	}
+	return nil
+}
+
+func getRemoteFileName(props collectionProperties, localFileName string) string {
+	return props.NameRemote + pitSeparator + strings.ToLower(localFileName)
 }
 
-func getRemoteFileNameAndURL(props collectionProperties, localFileName string) (string, string) {
-	remoteFileName := props.NameRemote + pitSeparator + strings.ToLower(localFileName)
-	remoteFileURL := fmt.Sprintf("https://pithub.blob.core.windows.net/%s/%s", getContainerName(), remoteFileName)
-	return remoteFileName, remoteFileURL
+func getRemoteFileNameAndURL(backend Backend, props collectionProperties, localFileName string) (string, string) {
+	remoteFileName := getRemoteFileName(props, localFileName)
+	return remoteFileName, backend.URL(remoteFileName)
 }
 
-func verifyCollectionDocument(props collectionProperties, doc documentProperties) error {
-	// Verify local file exists.
-	if !fileExists(doc.NameLocal) {
-		return errors.New(fmt.Sprintf("%s Document has been deleted\n", doc.NameLocal))
+func verifyCollectionDocument(op *operation, backend Backend, props collectionProperties, doc documentProperties) error {
+	report := DocumentReport{Name: doc.NameLocal, LocalMD5: doc.MD5, ETag: doc.ETag}
+	if len(doc.MovedFrom) != 0 {
+		report.State = StateRenamed
+		op.reportDocument(report)
+		fmt.Fprintf(op.out, "%s renamed from %s but not moved remotely with \"pit push\"\n", padRight(doc.NameLocal, " ", 20), doc.MovedFrom)
+		return nil
 	}
 
-	// Verify local document has not been modified since is was last added.
-	localFileMD5 := md5File(doc.NameLocal)
-	if doc.MD5 != localFileMD5 {
-		// Document has been updated locally but updated version has not been added.
-		fmt.Printf("%s updated but version has not been added with \"pit add %s\"\n",
-			padRight(doc.NameLocal, " ", 20), doc.NameLocal)
+	// Documents that were not downloaded by a sparse clone are expected to be missing.
+	if doc.Absent {
+		report.State = StateRemoteOnly
+		op.reportDocument(report)
+		fmt.Fprintf(op.out, "%s remote only, download with \"pit fetch %s\"\n", padRight(doc.NameLocal, " ", 20), doc.NameLocal)
 		return nil
 	}
 
 	// Verify remote document.
-	remoteFileName, remoteFileURL := getRemoteFileNameAndURL(props, doc.NameLocal)
-	remoteFileMD5, _, err := getRemoteFileMD5AndETag(remoteFileName)
-	if err != nil {
+	remoteFileName, remoteFileURL := getRemoteFileNameAndURL(backend, props, doc.NameLocal)
+	remoteFileMD5, remoteFileETag, err := getRemoteFileMD5AndETag(op.ctx, backend, remoteFileName)
+	if errors.Is(err, ErrNotFound) {
 		// Remote file not found likely because it has not been pushed.
-		fmt.Printf("%s added but not published with \"pit push\"\n", padRight(doc.NameLocal, " ", 20))
+		report.State = StateUnpushed
+		op.reportDocument(report)
+		fmt.Fprintf(op.out, "%s added but not published with \"pit push\"\n", padRight(doc.NameLocal, " ", 20))
 		return nil
+	} else if err != nil {
+		report.State = StateError
+		report.Error = err.Error()
+		op.reportDocument(report)
+		return errors.New(fmt.Sprintf("%s Error: %s", padRight(doc.NameLocal, " ", 20), err))
 	}
+
+	report.RemoteMD5 = remoteFileMD5
+	report.ETag = remoteFileETag
+	report.URL = remoteFileURL
 	if doc.MD5 != remoteFileMD5 {
 		// Local and remote files are not the same like because the local file has been updated
+		report.State = StateUnpushed
+		if !containsMD5(doc.PreviousMD5s, remoteFileMD5) {
+			// The remote version was not pushed from this computer.
+			report.State = StateConflict
+		}
+		op.reportDocument(report)
+		if report.State == StateConflict {
+			fmt.Fprintf(op.out, "%s version conflict, updated by another computer (use \"pit pull\", or \"pit push\" with --force, "+
+				"--keep-both, or --theirs)\n", padRight(doc.NameLocal, " ", 20))
+		} else {
+			fmt.Fprintf(op.out, "%s updated but not published with \"pit push\"\n", padRight(doc.NameLocal, " ", 20))
+		}
 		return nil
 	}
 
-	remotefileMD5, remoteFileETag, err := getRemoteFileMD5AndETag(remoteFileName)
-	if doc.MD5 != remotefileMD5 {
-		errorString := fmt.Sprintf("Fatal Error: %s MD5 has unexpected value\n", remoteFileURL)
-		errorString += fmt.Sprintf("  Current cloud MD5:  %s\n", remoteFileMD5)
-		errorString += fmt.Sprintf("  Local MD5: %s", doc.ETag)
-		return errors.New(errorString)
-	}
-
 	if doc.ETag != remoteFileETag {
-		errorString := fmt.Sprintf("Fatal Error: %s has been updated by another computer\n", remoteFileURL)
-		errorString += fmt.Sprintf("  Local ETag: %s\n", doc.ETag)
-		errorString += fmt.Sprintf("  Cloud ETag: %s", remoteFileETag)
-		return errors.New(errorString)
+		// The same version was pushed from another computer, or its metadata was updated, so the content is verified
+		// and only the ETag in the collection is stale. Push updates it the same way.
+		report.State = StateVerified
+		op.reportDocument(report)
+		fmt.Fprintf(op.out, "%s verified and shared as %s (ETag changed by another computer, \"pit push\" updates it)\n",
+			padRight(doc.NameLocal, " ", 20), remoteFileURL)
+		return nil
 	}
 
-	fmt.Printf("%s verified and shared as %s\n", padRight(doc.NameLocal, " ", 20), remoteFileURL)
+	report.State = StateVerified
+	op.reportDocument(report)
+	fmt.Fprintf(op.out, "%s verified and shared as %s\n", padRight(doc.NameLocal, " ", 20), remoteFileURL)
 	return nil
 }
 
@@ -118,76 +170,143 @@ func padRight(str, pad string, length int) string {
 	}
 }
 
-func verifyCollectionDocuments(props collectionProperties) {
+func verifyCollectionDocuments(op *operation, backend Backend, props collectionProperties) {
+	// Documents that were deleted or modified locally are listed after the documents that were verified, the same
+	// way as "git status".
+	var modified, missing []string
+
 	// Verify each document in collection.
 	for _, doc := range props.Documents {
-		err := verifyCollectionDocument(props, doc)
+		if !doc.Absent && len(doc.MovedFrom) == 0 {
+			if !fileExists(op.path(doc.NameLocal)) {
+				missing = append(missing, doc.NameLocal)
+				op.reportDocument(DocumentReport{Name: doc.NameLocal, State: StateMissing, LocalMD5: doc.MD5, ETag: doc.ETag})
+				continue
+			} else if localMD5, err := md5File(op.path(doc.NameLocal)); err != nil {
+				op.printDocumentLine(doc.NameLocal, "Error: unable to read\n%s", err)
+				op.reportDocument(DocumentReport{Name: doc.NameLocal, State: StateError, LocalMD5: doc.MD5, ETag: doc.ETag, Error: err.Error()})
+				continue
+			} else if localMD5 != doc.MD5 {
+				// Document has been updated locally but updated version has not been added.
+				modified = append(modified, doc.NameLocal)
+				op.reportDocument(DocumentReport{Name: doc.NameLocal, State: StateModified, LocalMD5: localMD5, ETag: doc.ETag})
+				continue
+			}
+		}
+
+		err := verifyCollectionDocument(op, backend, props, doc)
 		if err != nil {
-			fmt.Printf("%s\n", err)
+			fmt.Fprintf(op.out, "%s\n", err)
 		}
 	}
-}
 
-func collectionStatus() {
-	if !collectionExists() {
-		fmt.Printf("No collection initialized\n")
-	} else {
-		props, err := collectionRead()
-		check(err)
+	for _, removed := range props.Removed {
+		op.reportDocument(DocumentReport{Name: removed.NameLocal, State: StateRemoved, ETag: removed.ETag})
+		fmt.Fprintf(op.out, "%s removed but not %s\n", padRight(removed.NameLocal, " ", 20), removedDocumentStatus(removed))
+	}
+
+	if len(modified) > 0 || len(missing) > 0 {
+		fmt.Fprintf(op.out, "\nChanges not added with \"pit add\":\n")
+		fmt.Fprintf(op.out, "  (use \"pit add <document>\" to update the collection, or \"pit rm <document>\" to remove a missing document)\n")
+		for _, name := range modified {
+			fmt.Fprintf(op.out, "    modified:   %s\n", name)
+		}
+		for _, name := range missing {
+			fmt.Fprintf(op.out, "    missing:    %s\n", name)
+		}
+	}
+
+	untracked, err := untrackedDocuments(op, props)
+	for _, name := range untracked {
+		op.reportDocument(DocumentReport{Name: name, State: StateUntracked})
+	}
+	if err != nil {
+		op.reportCommandError(err)
+		fmt.Fprintf(op.out, "Error: unable to list untracked documents\n%s\n", err)
+	} else if len(untracked) > 0 {
+		fmt.Fprintf(op.out, "\nUntracked documents:\n")
+		fmt.Fprintf(op.out, "  (use \"pit add <document>\" to include them in the collection)\n")
+		for _, name := range untracked {
+			fmt.Fprintf(op.out, "    %s\n", name)
+		}
+	}
+}
 
-		fmt.Printf("Collection \"%s\"\n", props.NameLocal)
+func collectionStatus(op *operation) error {
+	props, err := collectionRead(op)
+	if err != nil {
+		return err
+	}
 
-		verifyCollectionDocuments(props)
+	backend, err := newCollectionBackend(op, props)
+	if err != nil {
+		return err
 	}
+
+	op.reportCollection(props.NameLocal)
+	fmt.Fprintf(op.out, "Collection \"%s\"\n", props.NameLocal)
+
+	verifyCollectionDocuments(op, backend, props)
+	return nil
 }
 
-func collectionRead() (collectionProperties, error) {
+func collectionRead(op *operation) (collectionProperties, error) {
 	var props collectionProperties
-	if !collectionExists() {
+	if !collectionExists(op) {
 		return props, errors.New("No collection initialized")
 	}
 
-	collectionFileData, err := ioutil.ReadFile(pitFileName)
-	check(err)
+	collectionFileData, err := ioutil.ReadFile(op.path(pitFileName))
+	if err != nil {
+		return props, err
+	}
 
 	err = json.Unmarshal(collectionFileData, &props)
-	check(err)
-
-	return props, nil
+	return props, err
 }
 
-func collectionWrite(props collectionProperties) error {
+func collectionWrite(op *operation, props collectionProperties) error {
 	collectionJSON, err := json.MarshalIndent(props, "", "    ")
 	// Non-formatted JSON would be generated with the following statement:
 	//     collectionJSON, err := json.Marshal(collectionProps)
-	check(err)
+	if err != nil {
+		return err
+	}
 
-	err = ioutil.WriteFile(pitFileName, collectionJSON, 0644)
+	err = ioutil.WriteFile(op.path(pitFileName), collectionJSON, 0644)
 	// The magic "0644" parameter above is filemode. Additional information can be found at:
 	// https://golang.org/pkg/os/#FileMode
 
 	return err
 }
 
-func collectionAddOrUpdate(filePathAndName string) error {
-	if !fileExists(filePathAndName) {
+func collectionAddOrUpdate(op *operation, filePathAndName string) error {
+	if !fileExists(op.path(filePathAndName)) {
 		return errors.New(fmt.Sprintf("File \"%s\"does not exist", filePathAndName))
 	}
 
-	props, err := collectionRead()
-	check(err)
+	props, err := collectionRead(op)
+	if err != nil {
+		return err
+	}
 
 	var doc documentProperties
-	doc.NameLocal = filepath.Base(filePathAndName)
-	doc.MD5 = md5File(filePathAndName)
+	doc.NameLocal, err = documentName(op, filePathAndName)
+	if err != nil {
+		return err
+	}
+	doc.MD5, err = md5File(op.path(filePathAndName))
+	if err != nil {
+		return err
+	}
 
 	for _, element := range props.Documents {
 		if doc.NameLocal == element.NameLocal {
 			if doc.MD5 == element.MD5 {
-				fmt.Printf("%s is already in the Collection and up to date\n", padRight(doc.NameLocal, " ", 20))
+				fmt.Fprintf(op.out, "%s is already in the Collection and up to date\n", padRight(doc.NameLocal, " ", 20))
 				return nil
 			} else if doc.MD5 != element.MD5 {
-				err = collectionUpdate(filePathAndName)
+				err = collectionUpdate(op, filePathAndName)
 				return err
 			}
 		}
@@ -195,17 +314,26 @@ func collectionAddOrUpdate(filePathAndName string) error {
 
 	// File was not in Collection so it needs to be added.
 	props.Documents = append(props.Documents, doc)
-	return collectionWrite(props)
+	return collectionWrite(op, props)
 }
 
-func collectionUpdate(filePathAndName string) error {
-	props, err := collectionRead()
-	check(err)
+func collectionUpdate(op *operation, filePathAndName string) error {
+	props, err := collectionRead(op)
+	if err != nil {
+		return err
+	}
+
+	fileName, err := documentName(op, filePathAndName)
+	if err != nil {
+		return err
+	}
 
 	for i := 0; i < len(props.Documents); i++ {
-		fileName := filepath.Base(filePathAndName)
 		if props.Documents[i].NameLocal == fileName {
-			currentMD5 := md5File(filePathAndName)
+			currentMD5, err := md5File(op.path(filePathAndName))
+			if err != nil {
+				return err
+			}
 			originalMD5 := props.Documents[i].MD5
 
 			if currentMD5 != originalMD5 {
@@ -215,205 +343,442 @@ func collectionUpdate(filePathAndName string) error {
 		}
 	}
 
-	return collectionWrite(props)
+	return collectionWrite(op, props)
 }
 
-func collectionAdd(filePathAndName string) error {
-	if !fileExists(filePathAndName) {
+func collectionAdd(op *operation, filePathAndName string) error {
+	if !fileExists(op.path(filePathAndName)) {
 		return errors.New(fmt.Sprintf("File \"%s\"does not exist", filePathAndName))
 	}
 
-	props, err := collectionRead()
-	check(err)
+	props, err := collectionRead(op)
+	if err != nil {
+		return err
+	}
 
 	var doc documentProperties
-	doc.NameLocal = filepath.Base(filePathAndName)
-	doc.MD5 = md5File(filePathAndName)
+	doc.NameLocal, err = documentName(op, filePathAndName)
+	if err != nil {
+		return err
+	}
+	doc.MD5, err = md5File(op.path(filePathAndName))
+	if err != nil {
+		return err
+	}
 
-	for _, element := range props.Documents {
+	// A document that is added again after "pit rm" must not be deleted by the next push.
+	if index := removedIndex(props, doc.NameLocal); index >= 0 {
+		doc.ETag = props.Removed[index].ETag
+		if doc.MD5 != props.Removed[index].MD5 {
+			doc.PreviousMD5s = []string{props.Removed[index].MD5}
+		}
+		props.Removed = append(props.Removed[:index], props.Removed[index+1:]...)
+	}
+
+	for i, element := range props.Documents {
 		if doc.NameLocal == element.NameLocal {
+			if element.Absent {
+				// The document was not downloaded by a sparse clone but now exists locally.
+				props.Documents[i].Absent = false
+				err = collectionWrite(op, props)
+				if err != nil {
+					return err
+				}
+			}
+
 			if doc.MD5 == element.MD5 {
-				fmt.Printf("%s is already in the Collection and up to date\n", padRight(doc.NameLocal, " ", 20))
+				fmt.Fprintf(op.out, "%s is already in the Collection and up to date\n", padRight(doc.NameLocal, " ", 20))
 				return nil
 			} else {
-				return collectionUpdate(filePathAndName)
+				return collectionUpdate(op, filePathAndName)
 			}
 		}
 	}
 
 	props.Documents = append(props.Documents, doc)
-	err = collectionWrite(props)
-	check(err)
+	return collectionWrite(op, props)
+}
+
+// Adds the files, the files in the folders (e.g. "clips"), and the files that match the glob patterns (e.g.
+// "*.mp4") that are not ignored by .pitignore. With all, every file in the collection is added.
+func collectionAddPaths(op *operation, paths []string, all bool) error {
+	list, err := readIgnoreList(op)
+	if err != nil {
+		return err
+	}
+
+	if all {
+		paths = []string{"."}
+	}
+	names, err := expandDocumentPaths(op, paths, list)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Fprintf(op.out, "Added \"%s\"\n", name)
+		err = collectionAdd(op, name)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func collectionPropsPrintJSON(props collectionProperties) {
+func collectionPropsPrintJSON(op *operation, props collectionProperties) error {
 	// Optionally store non-formatted json by utilizing:
 	//     collectionJSON, err := json.Marshal(collectionProps)
 	json, err := json.MarshalIndent(props, "", "    ")
-	check(err)
+	if err != nil {
+		return err
+	}
 
 	jsonString := string(json)
-	fmt.Println(jsonString)
+	fmt.Fprintln(op.out, jsonString)
+	return nil
 }
 
-func collectionGetInitialLocalName() string {
-	// Set the initial CollectionNameLocal to the current directory name.
-	path, err := os.Getwd()
-	check(err)
-	return filepath.Base(path)
+func collectionGetInitialLocalName(op *operation) (string, error) {
+	// Set the initial CollectionNameLocal to the name of the collection folder.
+	path, err := filepath.Abs(op.dir)
+	return filepath.Base(path), err
 }
 
 func collectionNewRemoteName() string {
 	return randomFileName(8)
 }
 
-func collectionDeleteLocalIfExist() {
-	if collectionExists() {
-		_ = os.Remove(pitFileName)
+func collectionDeleteLocalIfExist(op *operation) {
+	if collectionExists(op) {
+		_ = os.Remove(op.path(pitFileName))
 	}
 }
 
-func collectionPush() {
-	props, err := collectionRead()
+// How collectionPush() resolves a version conflict, i.e. a remote document that was updated by another computer.
+type ConflictResolution int
 
+const (
+	ConflictReport   ConflictResolution = iota // Report the conflict and do not upload the document.
+	ConflictForce                              // Overwrite the remote document with the local document.
+	ConflictKeepBoth                           // Upload the local document under a suffixed name and download the remote document.
+	ConflictTheirs                             // Discard the local change and download the remote document.
+)
+
+type PushOptions struct {
+	Resolution ConflictResolution
+	Jobs       int // The number of documents uploaded at the same time.
+}
+
+// Uploads the document and updates its ETag. The upload only succeeds if the remote document matches the access
+// conditions, otherwise a ConflictError is returned.
+func pushDocument(op *operation, backend Backend, props collectionProperties, doc *documentProperties, conditions accessConditions) error {
+	remoteFileName, remoteFileURL := getRemoteFileNameAndURL(backend, props, doc.NameLocal)
+
+	etag, err := uploadDocument(op, backend, doc.NameLocal, remoteFileName, conditions)
+	if err == nil {
+		// Only set the MD5 of the version that was just uploaded.
+		etag, err = setDocumentMetadataMD5(op.ctx, backend, remoteFileName, doc.MD5, accessConditions{IfMatch: etag})
+	}
+	if errors.Is(err, ErrConflict) {
+		return err
+	} else if err != nil {
+		return errors.New(fmt.Sprintf("unable to upload %s\n%s", remoteFileURL, err))
+	}
+
+	newRemoteMD5, _, err := getRemoteFileMD5AndETag(op.ctx, backend, remoteFileName)
 	if err != nil {
-		fmt.Printf("Error: unable to read collection\n%s", err)
-		return
+		return errors.New(fmt.Sprintf("unable to obtain MD5 or ETag for %s", remoteFileURL))
 	}
 
-	// If the local Collection json file  is updated, we will need to upload it at the end of the function.
-	collectFileModified := false
+	doc.ETag = etag
+	if doc.MD5 != newRemoteMD5 {
+		return errors.New(fmt.Sprintf("MD5 not updated correctly for %s", remoteFileURL))
+	}
 
-	// Check each Document in the Collection to see if it need to be uploaded.
-	for index, doc := range props.Documents {
-		newFile := false
-		updatedFile := false
-		uploadFile := false
+	return updateDocumentAliases(op.ctx, backend, props, *doc)
+}
 
-		remoteFileName := props.NameRemote + pitSeparator + strings.ToLower(doc.NameLocal)
-		remoteMDd5, remoteETag, err := getRemoteFileMD5AndETag(remoteFileName)
-		if err != nil {
-			if strings.Contains(err.Error(), "ServiceCode=BlobNotFound") {
-				// Document exists locally, but not remotely.
-				// Todo: Implement a better way to handle this error so that we are not comparing strings.
-				newFile = true
-				uploadFile = true
-			} else {
-				// Document exist remotely, be we are not able to get the remote file MD5 and ETag.
-				fmt.Printf("%s Error: %s\n", padRight(doc.NameLocal, " ", 20), err.Error())
-				break // Stop processing this document.
-			}
+// Returns the name under which the local version of a conflicting document is kept, e.g. "intro-laptop.mp4".
+func conflictDocumentName(op *operation, props collectionProperties, nameLocal string) string {
+	hostname, err := os.Hostname()
+	if err != nil || len(hostname) == 0 {
+		hostname = "local"
+	}
+	hostname = strings.ToLower(strings.Split(hostname, ".")[0])
+
+	ext := filepath.Ext(nameLocal)
+	base := strings.TrimSuffix(nameLocal, ext)
+	name := base + pitSeparator + hostname + ext
+	for i := 2; fileExists(op.path(name)) || documentIndex(props, name) >= 0; i++ {
+		name = fmt.Sprintf("%s%s%s%s%d%s", base, pitSeparator, hostname, pitSeparator, i, ext)
+	}
+
+	return name
+}
+
+// Replaces the local version of the document with the remote version.
+func acceptTheirVersion(op *operation, backend Backend, props collectionProperties, doc *documentProperties, remoteMD5 string, remoteETag string) error {
+	err := downloadDocument(op, backend, getRemoteFileName(props, doc.NameLocal), doc.NameLocal, remoteMD5)
+	if err != nil {
+		return err
+	}
+
+	acceptRemoteVersion(doc, remoteMD5, remoteETag, nil)
+	return nil
+}
+
+// Keeps the local version of the document as a new document, which is returned, and downloads the remote version.
+func keepBothVersions(op *operation, backend Backend, props collectionProperties, doc *documentProperties, remoteMD5 string, remoteETag string) (documentProperties, error) {
+	ours := documentProperties{NameLocal: conflictDocumentName(op, props, doc.NameLocal), MD5: doc.MD5}
+	err := copyFile(op.path(doc.NameLocal), op.path(ours.NameLocal))
+	if err != nil {
+		deleteFile(op.path(ours.NameLocal))
+		return documentProperties{}, err
+	}
+
+	err = pushDocument(op, backend, props, &ours, accessConditions{IfNoneMatch: etagAny})
+	if err != nil {
+		return ours, err
+	}
+
+	return ours, acceptTheirVersion(op, backend, props, doc, remoteMD5, remoteETag)
+}
+
+// The outcome of pushing one document. Workers only fill in results; the collection is updated once every
+// document has been pushed.
+type pushResult struct {
+	doc      documentProperties   // The document with its updated ETag, MD5, etc.
+	added    []documentProperties // Documents added while resolving a version conflict.
+	modified bool                 // True if the collection needs to be written and uploaded.
+	conflict bool                 // True if the document was not uploaded due to a version conflict.
+	err      error                // The error that stopped the document from being pushed.
+}
+
+// Records the outcome of pushing the document.
+func reportPushResult(op *operation, backend Backend, props collectionProperties, result pushResult) {
+	docs := append([]documentProperties{result.doc}, result.added...)
+	for i, doc := range docs {
+		report := DocumentReport{Name: doc.NameLocal, State: StateVerified, LocalMD5: doc.MD5, ETag: doc.ETag}
+		if doc.Absent {
+			report.State = StateRemoteOnly
+		} else {
+			report.URL = backend.URL(getRemoteFileName(props, doc.NameLocal))
 		}
 
-		if !newFile {
-			if doc.ETag != remoteETag || doc.MD5 != remoteMDd5 {
-				// Document exists locally and remotely, but has been updated locally.
-				updatedFile = true
-			}
+		// Documents added while resolving a version conflict (i > 0) were pushed if the document was.
+		if result.conflict {
+			report.State = StateConflict
+		} else if result.err != nil && i == 0 {
+			report.State = StateError
+			report.Error = result.err.Error()
+		} else if report.State == StateVerified {
+			report.RemoteMD5 = doc.MD5
 		}
+		op.reportDocument(report)
+	}
+}
 
-		_, remoteFileURL := getRemoteFileNameAndURL(props, doc.NameLocal)
-		if !newFile && !updatedFile {
-			// The Document exists locally and remotely and the files are the same (matching eTags and MD5s).
-			fmt.Printf("%s verified and shared as %s\n", padRight(doc.NameLocal, " ", 20), remoteFileURL)
+// Checks if the document needs to be uploaded and uploads it.
+func pushCollectionDocument(op *operation, backend Backend, props collectionProperties, doc documentProperties, options PushOptions) pushResult {
+	result := pushResult{doc: doc}
+	if len(doc.MovedFrom) != 0 {
+		// Renamed with "pit mv", so the remote document is moved before it is checked.
+		err := moveRemoteDocument(op.ctx, backend, props, &result.doc, options)
+		if errors.Is(err, ErrConflict) {
+			result.conflict = true
+			op.printDocumentLine(doc.NameLocal, "Error: not moved due to version conflict")
+			return result
+		} else if err != nil {
+			result.err = err
+			op.printDocumentLine(doc.NameLocal, "Error: %s", err)
+			return result
 		}
+		result.modified = true
+		op.printDocumentLine(doc.NameLocal, "moved from %s", backend.URL(getRemoteFileName(props, doc.MovedFrom)))
+		doc = result.doc
+	}
 
-		if updatedFile {
-			// Check if the remote file was a previously uploaded file from this computer.
-			for _, previousLocalMD5 := range props.Documents[index].PreviousMD5s {
-				if remoteMDd5 == previousLocalMD5 {
-					// One of the previous MD5s match so it should be safe to upload the updated file.
-					uploadFile = true
-					break
-				}
+	if doc.Absent {
+		// Not downloaded by a sparse clone, so there is nothing to upload.
+		op.printDocumentLine(doc.NameLocal, "remote only")
+		return result
+	}
+	uploadFile := false
+	var conditions accessConditions
+
+	remoteFileName, remoteFileURL := getRemoteFileNameAndURL(backend, props, doc.NameLocal)
+	remoteMD5, remoteETag, err := getRemoteFileMD5AndETag(op.ctx, backend, remoteFileName)
+	if errors.Is(err, ErrNotFound) {
+		// Document exists locally, but not remotely. Fail if another computer creates it in the meantime.
+		uploadFile = true
+		conditions = accessConditions{IfNoneMatch: etagAny}
+	} else if err != nil {
+		// Document exist remotely, be we are not able to get the remote file MD5 and ETag.
+		result.err = err
+		op.printDocumentLine(doc.NameLocal, "Error: %s", err)
+		return result
+	} else if doc.ETag == remoteETag && doc.MD5 == remoteMD5 {
+		// The Document exists locally and remotely and the files are the same (matching eTags and MD5s).
+		op.printDocumentLine(doc.NameLocal, "verified and shared as %s", remoteFileURL)
+		return result
+	} else if doc.MD5 == remoteMD5 {
+		// The same version was pushed from another computer so only the ETag needs updating.
+		result.doc.ETag = remoteETag
+		result.modified = true
+		op.printDocumentLine(doc.NameLocal, "verified and shared as %s", remoteFileURL)
+		return result
+	} else if containsMD5(doc.PreviousMD5s, remoteMD5) {
+		// The remote file was previously uploaded from this computer so it is safe to upload the updated file,
+		// provided it is still the version this computer knows about.
+		uploadFile = true
+		conditions = accessConditionsForETag(doc.ETag)
+	} else {
+		// The remote Document has an MD5 that is not recognized in the local Collection. Therefore, the Document
+		// was likely updated from a different computer and we risk overwriting changes.
+		switch options.Resolution {
+		case ConflictForce:
+			op.printDocumentLine(doc.NameLocal, "version conflict resolved by overwriting the remote version")
+			uploadFile = true
+			// Only overwrite the remote version that was seen, not one uploaded since.
+			conditions = accessConditions{IfMatch: remoteETag}
+		case ConflictKeepBoth:
+			result.modified = true
+			ours, err := keepBothVersions(op, backend, props, &result.doc, remoteMD5, remoteETag)
+			if len(ours.NameLocal) > 0 {
+				result.added = append(result.added, ours)
 			}
-
-			if !uploadFile {
-				// The remote Document has an MD5 that is not recognized in the local Collection. Therefore, the Document
-				// was likely updated from a different computer and we risk overwriting changes.
-				fmt.Printf("%s Error: aborting upload due to version conflict\n", padRight(doc.NameLocal, " ", 20))
-				break
+			if err != nil {
+				result.err = err
+				op.printDocumentLine(doc.NameLocal, "Error: %s", err)
+				return result
+			}
+			op.printDocumentLine(doc.NameLocal, "version conflict resolved by keeping the local version as %s and downloading the remote version",
+				ours.NameLocal)
+		case ConflictTheirs:
+			result.modified = true
+			err = acceptTheirVersion(op, backend, props, &result.doc, remoteMD5, remoteETag)
+			if err != nil {
+				result.err = err
+				op.printDocumentLine(doc.NameLocal, "Error: %s", err)
+				return result
 			}
+			op.printDocumentLine(doc.NameLocal, "version conflict resolved by discarding the local version and downloading the remote version")
+		default:
+			result.conflict = true
+			op.printDocumentLine(doc.NameLocal, "Error: not uploaded due to version conflict")
 		}
+	}
 
-		if uploadFile {
-			collectFileModified = true
+	if uploadFile {
+		result.modified = true
+
+		err = pushDocument(op, backend, props, &result.doc, conditions)
+		if errors.Is(err, ErrConflict) {
+			result.conflict = true
+			op.printDocumentLine(doc.NameLocal, "Error: not uploaded due to version conflict")
+		} else if err != nil {
+			result.err = err
+			op.printDocumentLine(doc.NameLocal, "Error: %s", err)
+		}
+	}
 
-			uploadDocument(getContainerName(), doc.NameLocal, remoteFileName)
-			setDocumentMetadataMD5(getContainerName(), remoteFileName, doc.MD5)
+	return result
+}
 
-			newRemoteMD5, newRemoteETag, err := getRemoteFileMD5AndETag(remoteFileName)
-			if err != nil {
-				fmt.Printf("%s Error: unable to obtain MD5 or ETag for %s\n", padRight(doc.NameLocal, " ", 20), remoteFileURL)
-				break
-			}
+func collectionPush(op *operation, options PushOptions) error {
+	props, err := collectionRead(op)
+	if err != nil {
+		return err
+	}
+	op.reportCollection(props.NameLocal)
 
-			if doc.MD5 != newRemoteMD5 {
-				fmt.Printf("%s Error: MD5 not updated correctly for for %s\n", padRight(doc.NameLocal, " ", 20), remoteFileURL)
-			}
+	backend, err := newCollectionBackend(op, props)
+	if err != nil {
+		return err
+	}
+
+	// Collections that were initialized before they were bound, or without a Container, are bound by the first push.
+	bound := false
+	if len(props.URL) == 0 {
+		props.URL = backendContainerURL(backend)
+		props.Profile = new(accountProperties).profileForURL(props.URL)
+		bound = true
+	}
 
-			props.Documents[index].ETag = newRemoteETag
+	// Check each Document in the Collection to see if it need to be uploaded.
+	results := make([]pushResult, len(props.Documents))
+	forEachParallel(len(props.Documents), options.Jobs, func(index int) {
+		results[index] = pushCollectionDocument(op, backend, props, props.Documents[index], options)
+	})
+
+	// If the local Collection json file  is updated, we will need to upload it at the end of the function.
+	collectFileModified := bound
+	conflicts := 0
+	for index, result := range results {
+		reportPushResult(op, backend, props, result)
+		props.Documents[index] = result.doc
+		props.Documents = append(props.Documents, result.added...)
+		if result.modified {
+			collectFileModified = true
+		}
+		if result.conflict {
+			conflicts++
 		}
 	}
 
-	if collectFileModified {
+	if pushRemovedDocuments(op, backend, &props, options) {
+		collectFileModified = true
+	}
+
+	if conflicts > 0 {
+		fmt.Fprintf(op.out, "%d document(s) not uploaded due to version conflicts. Use \"pit pull\" to get the remote versions, "+
+			"or \"pit push\" with --force, --keep-both, or --theirs to resolve the conflicts.\n", conflicts)
+	}
+
+	// The remote Collection can also be out of date when an earlier upload of it failed.
+	if collectFileModified || remoteCollectionOutOfDate(op.ctx, backend, props) {
 		// Write the local Collection if was modified (e.g. ETag).
-		err = collectionWrite(props)
+		err = collectionWrite(op, props)
 		if err != nil {
-			fmt.Printf("Error: unable to updated Collection\n")
+			return err
 		}
 
-		// Upload (overwrite if necessary) the modified Collection json file.
+		// Upload the modified Collection json file, provided no other computer uploaded it since it was last seen.
 		collectionLocalFileName := pitFileName
 		collectionRemoteFileName := props.NameRemote + ".json"
-		uploadDocument(getContainerName(), collectionLocalFileName, collectionRemoteFileName)
-	}
-}
-
-func collectionPushBK1() {
-	props, err := collectionRead()
-	check(err)
-
-	// Todo: Refactor code so that we do not get ALL of the remote files to see if a given file exists and is current.
-	documentNames := getDocumentNames(getContainerName())
-	for docIndex, doc := range props.Documents {
-		remoteFileName := props.NameRemote + pitSeparator + strings.ToLower(doc.NameLocal)
-		remoteFileExists := false
-		for _, documentName := range documentNames {
-			// Todo: consider optimizing by breaking out of loop when file is found.
-			if strings.EqualFold(remoteFileName, documentName) {
-				remoteFileExists = true
-				log.Println(fmt.Sprintf("Verified: %s", doc.NameLocal))
-
-				// BugBug: This does not look right as it references 'ovun'.
-				log.Println(fmt.Sprintf("    Is: %s%s", "https://pithub.blob.core.windows.net/ovun/", remoteFileName))
-			}
+		etag, err := uploadDocument(op, backend, collectionLocalFileName, collectionRemoteFileName, accessConditionsForETag(props.ETag))
+		if errors.Is(err, ErrConflict) {
+			return fmt.Errorf("Collection not uploaded because it was %w. Use \"pit pull\" and then \"pit push\" again", ErrConflict)
+		} else if err != nil {
+			return fmt.Errorf("unable to upload Collection: %w", err)
 		}
 
-		if !remoteFileExists {
-			uploadDocument(getContainerName(), doc.NameLocal, remoteFileName)
-			setDocumentMetadataMD5(getContainerName(), remoteFileName, doc.MD5)
-			// Todo: Verify MD5 and set local ETag.
+		props.ETag = etag
+		return collectionWrite(op, props)
+	}
+	return nil
+}
 
-			_, remoteFileETag, err := getRemoteFileMD5AndETag(remoteFileName)
-			check(err)
+// Returns true if the documents in the remote Collection differ from the local Collection.
+func remoteCollectionOutOfDate(ctx context.Context, backend Backend, props collectionProperties) bool {
+	remoteProps, err := readRemoteCollection(ctx, backend, props.NameRemote)
+	if errors.Is(err, ErrNotFound) {
+		return len(props.Documents) != 0
+	} else if err != nil {
+		return false
+	} else if len(remoteProps.Documents) != len(props.Documents) {
+		return true
+	}
 
-			props.Documents[docIndex].ETag = remoteFileETag
+	for _, remoteDoc := range remoteProps.Documents {
+		index := documentIndex(props, remoteDoc.NameLocal)
+		if index < 0 || props.Documents[index].MD5 != remoteDoc.MD5 || props.Documents[index].ETag != remoteDoc.ETag {
+			return true
 		}
 	}
-
-	// Write the local collection as it may have been modified above (e.g. ETag).
-	err = collectionWrite(props)
-	check(err)
-
-	// Upload (overwrite if necessary) the collection json file.
-	collectionLocalFileName := pitFileName
-	collectionRemoteFileName := props.NameRemote + ".json"
-	uploadDocument(getContainerName(), collectionLocalFileName, collectionRemoteFileName)
+	return false
 }
 
-/* Todo: Consider if we need to check local file that are not in the Collection as part of the status feature.
 func fileInCollection(props collectionProperties, fileName string) bool {
 	for _, doc := range props.Documents {
 		if doc.NameLocal == fileName {
@@ -423,38 +788,20 @@ func fileInCollection(props collectionProperties, fileName string) bool {
 	return false
 }
 
-func verifyLocalFileInCollection(fileName string) error {
-	// Todo: Implement function.
-	return nil
-}
-
-func collectionCheckDocuments(props collectionProperties) {
-	// Verify all local files are in collection.
-	var files []string
+// Returns the files in the collection folder, and its sub-folders, that are not in the Collection and are not
+// ignored by .pitignore.
+func untrackedDocuments(op *operation, props collectionProperties) ([]string, error) {
+	list, err := readIgnoreList(op)
+	if err != nil {
+		return nil, err
+	}
 
-	root := "./"
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		files = append(files, path)
+	var names []string
+	err = walkDocuments(op, ".", list, func(name string) error {
+		if !fileInCollection(props, name) {
+			names = append(names, name)
+		}
 		return nil
 	})
-	check(err)
-
-	for _, file := range files {
-		info, err := os.Stat(file)
-		check(err)
-		if !info.IsDir() {
-			if !fileInCollection(props, file) {
-				if (file != pitFileName) && (file != ".DS_Store") {
-					fmt.Printf("\"%s\" not in Collection\n", file)
-				}
-			}
-		}
-	}
-
-	// Verify remote copy of collection
-	remoteCollectionFileName := props.NameRemote + ".json"
-	fmt.Printf("ContainerName=\"%s\" remoteCollectionName=\"%s\n", getContainerName(), remoteCollectionFileName)
-
-	// downloadDocument(containerName, pitFileName, remoteCollectionFileName)
+	return names, err
 }
-*/
@@ -0,0 +1,623 @@
+package pit
+
+// End-to-end tests of the init, add, push, status, and clone flow. The tests run against the in-process fake blob
+// server (see fakeblob_test.go) and the file backend, so no network access or Azure account is needed.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testContainerName = "testtest"
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+type testEnvironment struct {
+	t      *testing.T
+	root   string
+	server *fakeBlobServer
+	op     *operation // Runs the operations of the test in the working directory, see chdir().
+}
+
+// Writes to the stdout of the moment, so that captureOutput() captures the output of the operations.
+type stdoutWriter struct{}
+
+func (stdoutWriter) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// Creates a user app folder whose only Container is the given Container and restores the working directory
+// when the test completes.
+func newTestEnvironmentWithContainer(t *testing.T, container containerProperties) *testEnvironment {
+	te := &testEnvironment{t: t, root: t.TempDir(), op: &operation{ctx: context.Background(), out: stdoutWriter{}}}
+
+	for _, name := range []string{"AZURE_STORAGE_CONNECTION_STRING", "AZURE_STORAGE_ACCOUNT",
+		"AZURE_STORAGE_ACCESS_KEY", "AZURE_CONTAINER_NAME"} {
+		t.Setenv(name, "")
+	}
+
+	pitHome := filepath.Join(te.root, "pit-home")
+	if err := os.Mkdir(pitHome, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PIT_HOME", pitHome)
+
+	account := new(accountProperties)
+	account.Description = accountFileDescription
+	account.Containers = []containerProperties{container}
+	if err := account.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+
+	return te
+}
+
+// Creates a test environment backed by the fake blob server.
+func newTestEnvironment(t *testing.T) *testEnvironment {
+	server := newFakeBlobServer()
+	t.Cleanup(server.close)
+
+	var container containerProperties
+	container.Type = "azure"
+	container.Account = azuriteAccountName
+	container.Key = azuriteAccountKey
+	container.Endpoint = server.endpoint()
+	container.Name = testContainerName
+	container.Default = "yes"
+
+	te := newTestEnvironmentWithContainer(t, container)
+	te.server = server
+	return te
+}
+
+// Returns the backend of the default Container.
+func (te *testEnvironment) backend(t *testing.T) Backend {
+	backend, err := newBackend(te.op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+// Changes the working directory, and the folder of the operations, to <root>/<computer>/<collection>, creating it
+// if needed. Each computer directory simulates a different computer sharing the same Container.
+func (te *testEnvironment) chdir(computer string, collection string) {
+	dir := filepath.Join(te.root, computer, collection)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		te.t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		te.t.Fatal(err)
+	}
+	te.op.dir = dir
+}
+
+// Changes the working directory to the folder of the collection that was just cloned.
+func (te *testEnvironment) chdirCloned() {
+	if err := os.Chdir(te.op.dir); err != nil {
+		te.t.Fatal(err)
+	}
+}
+
+// Changes the working directory to <root>/<computer>, e.g. before cloning.
+func (te *testEnvironment) chdirComputer(computer string) {
+	te.chdir(computer, "")
+}
+
+func writeTestFile(t *testing.T, name string, content string) {
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func md5TestFile(t *testing.T, name string) string {
+	md5, err := md5File(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return md5
+}
+
+// Reads the collection in the working directory.
+func readTestCollection(t *testing.T) collectionProperties {
+	props, err := collectionRead(new(operation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return props
+}
+
+// Returns everything written to stdout while f runs.
+func captureOutput(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	output := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		output <- string(data)
+	}()
+
+	defer func() {
+		os.Stdout = stdout
+	}()
+	f()
+	w.Close()
+	return <-output
+}
+
+func assertContains(t *testing.T, output string, expected string) {
+	t.Helper()
+	if !strings.Contains(output, expected) {
+		t.Errorf("expected output to contain %q, got:\n%s", expected, output)
+	}
+}
+
+func TestStatusWithoutCollection(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+
+	err := collectionStatus(te.op)
+	if err == nil || err.Error() != "No collection initialized" {
+		t.Fatalf("expected \"No collection initialized\", got %v", err)
+	}
+}
+
+func TestInitAddPushStatusClone(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "intro video")
+	writeTestFile(t, "syllabus.pdf", "syllabus")
+	if err := collectionAdd(te.op, "intro.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := collectionAdd(te.op, "syllabus.pdf"); err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "intro.mp4            added but not published")
+	assertContains(t, output, "syllabus.pdf         added but not published")
+
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	props := readTestCollection(t)
+	remoteName := getRemoteFileName(props, "intro.mp4")
+	blob := te.server.blob(testContainerName, remoteName)
+	if blob == nil {
+		t.Fatalf("%s was not uploaded", remoteName)
+	}
+	if string(blob.data) != "intro video" {
+		t.Errorf("unexpected remote content %q", blob.data)
+	}
+	if blob.contentType != "video/mp4" {
+		t.Errorf("unexpected content type %q", blob.contentType)
+	}
+	if blob.metadata[pitMD5tag] != md5TestFile(t, "intro.mp4") {
+		t.Errorf("unexpected %s metadata %q", pitMD5tag, blob.metadata[pitMD5tag])
+	}
+	if props.Documents[0].ETag != strings.Trim(blob.etag, "\"") {
+		t.Errorf("local ETag %q does not match remote ETag %q", props.Documents[0].ETag, blob.etag)
+	}
+	if te.server.blob(testContainerName, props.NameRemote+".json") == nil {
+		t.Errorf("collection manifest was not uploaded")
+	}
+
+	output = captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "intro.mp4            verified and shared as "+
+		te.server.endpoint()+"/"+testContainerName+"/"+remoteName)
+
+	// Clone the collection on a second computer.
+	te.chdirComputer("computer2")
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{}) })
+	te.chdirCloned()
+
+	if readTestFile(t, "intro.mp4") != "intro video" || readTestFile(t, "syllabus.pdf") != "syllabus" {
+		t.Errorf("cloned documents do not match")
+	}
+	output = captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "intro.mp4            verified and shared as")
+	assertContains(t, output, "syllabus.pdf         verified and shared as")
+}
+
+func TestAddUpdatedDocument(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "version 1")
+	collectionAdd(te.op, "intro.mp4")
+	originalMD5 := md5TestFile(t, "intro.mp4")
+
+	writeTestFile(t, "intro.mp4", "version 2")
+	output := captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "modified:   intro.mp4")
+
+	collectionAdd(te.op, "intro.mp4")
+	props := readTestCollection(t)
+	if len(props.Documents) != 1 {
+		t.Fatalf("expected 1 document, got %d", len(props.Documents))
+	}
+	doc := props.Documents[0]
+	if doc.MD5 != md5TestFile(t, "intro.mp4") {
+		t.Errorf("MD5 was not updated")
+	}
+	if len(doc.PreviousMD5s) != 1 || doc.PreviousMD5s[0] != originalMD5 {
+		t.Errorf("unexpected PreviousMD5s %v", doc.PreviousMD5s)
+	}
+}
+
+func TestPushUpdatedDocument(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "version 1")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	writeTestFile(t, "intro.mp4", "version 2")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	props := readTestCollection(t)
+	blob := te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4"))
+	if string(blob.data) != "version 2" {
+		t.Errorf("unexpected remote content %q", blob.data)
+	}
+
+	output := captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "intro.mp4            verified and shared as")
+}
+
+func TestPushVersionConflict(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "original")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	// A second computer clones the collection and pushes an update.
+	te.chdirComputer("computer2")
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{}) })
+	te.chdirCloned()
+	writeTestFile(t, "intro.mp4", "update from computer2")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	// The first computer updates the same document without having the second computer's update.
+	te.chdir("computer1", "videos")
+	writeTestFile(t, "intro.mp4", "update from computer1")
+	collectionAdd(te.op, "intro.mp4")
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	assertContains(t, output, "intro.mp4            Error: not uploaded due to version conflict")
+
+	props := readTestCollection(t)
+	blob := te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4"))
+	if string(blob.data) != "update from computer2" {
+		t.Errorf("the second computer's update was overwritten: %q", blob.data)
+	}
+}
+
+func TestFileBackendPushClone(t *testing.T) {
+	var container containerProperties
+	container.Type = "file"
+	container.Name = testContainerName
+	container.Default = "yes"
+	te := newTestEnvironmentWithContainer(t, container)
+
+	// The Path is only known once the test environment has been created.
+	account := new(accountProperties)
+	if err := account.read(); err != nil {
+		t.Fatal(err)
+	}
+	account.Containers[0].Path = filepath.Join(te.root, "nas")
+	if err := account.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "intro video")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	output := captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "intro.mp4            verified and shared as file://")
+
+	te.chdirComputer("computer2")
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{}) })
+	te.chdirCloned()
+	if readTestFile(t, "intro.mp4") != "intro video" {
+		t.Errorf("cloned document does not match")
+	}
+
+	var manifest collectionProperties
+	data, err := ioutil.ReadFile(filepath.Join(te.root, "nas", testContainerName, manifestName(readTestCollection(t))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Documents) != 1 || manifest.Documents[0].NameLocal != "intro.mp4" {
+		t.Errorf("unexpected manifest documents %v", manifest.Documents)
+	}
+}
+
+func manifestName(props collectionProperties) string {
+	return props.NameRemote + ".json"
+}
+
+// Leaves computer1 with local updates to intro.mp4 and notes.pdf, where intro.mp4 was also updated by computer2.
+func setupPushConflict(t *testing.T, te *testEnvironment) {
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "original")
+	writeTestFile(t, "notes.pdf", "original notes")
+	collectionAdd(te.op, "intro.mp4")
+	collectionAdd(te.op, "notes.pdf")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	te.chdirComputer("computer2")
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{}) })
+	te.chdirCloned()
+	writeTestFile(t, "intro.mp4", "update from computer2")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	te.chdir("computer1", "videos")
+	writeTestFile(t, "intro.mp4", "update from computer1")
+	writeTestFile(t, "notes.pdf", "updated notes")
+	collectionAdd(te.op, "intro.mp4")
+	collectionAdd(te.op, "notes.pdf")
+}
+
+func TestPushConflictReportedPerDocument(t *testing.T) {
+	te := newTestEnvironment(t)
+	setupPushConflict(t, te)
+
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	assertContains(t, output, "intro.mp4            Error: not uploaded due to version conflict")
+	assertContains(t, output, "1 document(s) not uploaded due to version conflicts")
+
+	// The conflict does not stop the documents after it from being pushed.
+	props := readTestCollection(t)
+	if blob := te.server.blob(testContainerName, getRemoteFileName(props, "notes.pdf")); string(blob.data) != "updated notes" {
+		t.Errorf("notes.pdf was not pushed: %q", blob.data)
+	}
+}
+
+func TestPushConflictResolution(t *testing.T) {
+	tests := []struct {
+		resolution    ConflictResolution
+		output        string
+		localContent  string
+		remoteContent string
+		documents     int
+	}{
+		{ConflictForce, "overwriting the remote version", "update from computer1", "update from computer1", 2},
+		{ConflictTheirs, "discarding the local version", "update from computer2", "update from computer2", 2},
+		{ConflictKeepBoth, "keeping the local version as intro-", "update from computer2", "update from computer2", 3},
+	}
+
+	for _, test := range tests {
+		te := newTestEnvironment(t)
+		setupPushConflict(t, te)
+
+		output := captureOutput(t, func() { collectionPush(te.op, PushOptions{Resolution: test.resolution}) })
+		assertContains(t, output, test.output)
+
+		props := readTestCollection(t)
+		if len(props.Documents) != test.documents {
+			t.Fatalf("resolution %d: expected %d documents, got %d", test.resolution, test.documents, len(props.Documents))
+		}
+		if readTestFile(t, "intro.mp4") != test.localContent {
+			t.Errorf("resolution %d: unexpected local content %q", test.resolution, readTestFile(t, "intro.mp4"))
+		}
+		remoteName := getRemoteFileName(props, "intro.mp4")
+		if blob := te.server.blob(testContainerName, remoteName); string(blob.data) != test.remoteContent {
+			t.Errorf("resolution %d: unexpected remote content %q", test.resolution, blob.data)
+		}
+
+		if test.resolution == ConflictKeepBoth {
+			ours := props.Documents[2]
+			if readTestFile(t, ours.NameLocal) != "update from computer1" {
+				t.Errorf("local version was not kept as %s", ours.NameLocal)
+			}
+			if blob := te.server.blob(testContainerName, getRemoteFileName(props, ours.NameLocal)); string(blob.data) != "update from computer1" {
+				t.Errorf("local version was not pushed as %s", ours.NameLocal)
+			}
+		}
+
+		// Every resolution leaves the collection without conflicts once the Collection from computer2 is pulled.
+		output = captureOutput(t, func() { collectionPull(te.op, false) })
+		output += captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+		if strings.Contains(output, "Error") {
+			t.Errorf("resolution %d: unexpected errors after resolving:\n%s", test.resolution, output)
+		}
+		if remoteProps, err := readRemoteCollection(context.Background(), te.backend(t), props.NameRemote); err != nil ||
+			len(remoteProps.Documents) != test.documents {
+			t.Errorf("resolution %d: remote Collection not updated: %v %+v", test.resolution, err, remoteProps.Documents)
+		}
+	}
+}
+
+func TestPushDocumentConditions(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "original")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	backend := te.backend(t)
+	writeTestFile(t, "intro.mp4", "update")
+	collectionAdd(te.op, "intro.mp4")
+	props := readTestCollection(t)
+
+	// Another computer updates the document after this computer last saw it.
+	remoteName := getRemoteFileName(props, "intro.mp4")
+	te.server.touch(testContainerName, remoteName)
+	err := pushDocument(te.op, backend, props, &props.Documents[0], accessConditionsForETag(props.Documents[0].ETag))
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a version conflict for a stale ETag, got %v", err)
+	}
+
+	// A document that another computer created in the meantime is not overwritten.
+	err = pushDocument(te.op, backend, props, &props.Documents[0], accessConditions{IfNoneMatch: etagAny})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a version conflict for an existing document, got %v", err)
+	}
+	if blob := te.server.blob(testContainerName, remoteName); string(blob.data) != "original" {
+		t.Errorf("remote document was overwritten: %q", blob.data)
+	}
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.RemoteName != remoteName {
+		t.Errorf("unexpected conflict error %v", err)
+	}
+}
+
+func TestPushCollectionConflict(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "original")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	te.chdirComputer("computer2")
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{}) })
+	te.chdirCloned()
+	writeTestFile(t, "notes.pdf", "notes from computer2")
+	collectionAdd(te.op, "notes.pdf")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	// The Collection uploaded by computer2 is not overwritten by computer1.
+	te.chdir("computer1", "videos")
+	writeTestFile(t, "outro.mp4", "outro from computer1")
+	collectionAdd(te.op, "outro.mp4")
+	var err error
+	captureOutput(t, func() { err = collectionPush(te.op, PushOptions{}) })
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected the Collection not to be uploaded due to a conflict, got %v", err)
+	}
+
+	props := readTestCollection(t)
+	remoteProps, err := readRemoteCollection(context.Background(), te.backend(t), props.NameRemote)
+	if err != nil || documentIndex(remoteProps, "notes.pdf") < 0 {
+		t.Fatalf("Collection from computer2 was overwritten: %v %+v", err, remoteProps.Documents)
+	}
+
+	// After a pull, the push uploads a Collection with the documents of both computers.
+	captureOutput(t, func() { collectionPull(te.op, false) })
+	output := captureOutput(t, func() { err = collectionPush(te.op, PushOptions{}) })
+	if err != nil || strings.Contains(output, "Error") {
+		t.Errorf("unexpected errors after pull: %v\n%s", err, output)
+	}
+	remoteProps, err = readRemoteCollection(context.Background(), te.backend(t), props.NameRemote)
+	if err != nil || len(remoteProps.Documents) != 3 {
+		t.Errorf("remote Collection not updated: %v %+v", err, remoteProps.Documents)
+	}
+	if readTestCollection(t).ETag != remoteProps.ETag {
+		t.Errorf("local Collection does not record the ETag of the remote Collection")
+	}
+}
+
+func TestFileBackendConditions(t *testing.T) {
+	root := t.TempDir()
+	backend, err := newFileBackend(containerProperties{Type: "file", Path: root, Name: "videos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	localName := filepath.Join(root, "intro.mp4")
+	if err := ioutil.WriteFile(localName, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	etag, err := backend.Put(context.Background(), localName, "intro.mp4", accessConditions{IfNoneMatch: etagAny})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Put(context.Background(), localName, "intro.mp4", accessConditions{IfNoneMatch: etagAny}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a version conflict for an existing document, got %v", err)
+	}
+
+	newETag, err := backend.SetMetadata(context.Background(), "intro.mp4", map[string]string{pitMD5tag: "md5"}, accessConditions{IfMatch: etag})
+	if err != nil || newETag == etag {
+		t.Fatalf("SetMetadata failed: %v", err)
+	}
+	if _, err := backend.Put(context.Background(), localName, "intro.mp4", accessConditions{IfMatch: etag}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a version conflict for a stale ETag, got %v", err)
+	}
+	if _, err := backend.Put(context.Background(), localName, "intro.mp4", accessConditions{IfMatch: newETag}); err != nil {
+		t.Errorf("Put with the current ETag failed: %v", err)
+	}
+}
+
+func TestStatusPrintsUnpushedAndConflictingDocuments(t *testing.T) {
+	te := newTestEnvironment(t)
+	setupRemoteUpdate(t, te)
+
+	// computer1 has not seen the update that computer2 pushed.
+	assertContains(t, captureOutput(t, func() { collectionStatus(te.op) }), "intro.mp4            version conflict, updated by another computer")
+
+	te.chdir("computer2", "videos")
+	writeTestFile(t, "intro.mp4", "second update from computer2")
+	collectionAdd(te.op, "intro.mp4")
+	assertContains(t, captureOutput(t, func() { collectionStatus(te.op) }), "intro.mp4            updated but not published with \"pit push\"")
+}
+
+func TestStatusVerifiesDocumentWithStaleETag(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "original")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	// Another computer pushed the same content, so only the ETag changed.
+	te.server.touch(testContainerName, getRemoteFileName(readTestCollection(t), "intro.mp4"))
+	te.op.report = &Report{Command: "status"}
+	assertContains(t, captureOutput(t, func() { collectionStatus(te.op) }), "intro.mp4            verified and shared as")
+	if report := te.op.finishReport(); report.Summary[StateVerified] != 1 || report.Summary[StateConflict] != 0 {
+		t.Errorf("expected a verified document, got %+v", report.Summary)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package pit
 
 // BugBug: Why is the following line included?
 // const pitProductionContainerName = "nvm4zqwm"
@@ -8,11 +8,15 @@ package main
 // variable names are utilized by Go in the creation of JSON files. 
 
 const pitFileName = ".pit.json"
+const pitUploadsFileName = ".pit.uploads.json"
+const pitIgnoreFileName = ".pitignore"
 const pitMD5tag = "pitmd5"
 const pitSeparator = "-"
+const pitArchivePrefix = "archive/"
 const userAppFolderName = ".pit"
 const userAppLogFileName = "log.txt"
 const userAppAccountFileName = "account.json"
+const userAppCredentialsFileName = "credentials.json"
 
 // Note that the Go json.Marshal() function only exports fields that start with an upper case name. 
 type collectionProperties struct {
@@ -21,8 +25,10 @@ type collectionProperties struct {
 	Created        string
 	Updated        string
 	ETag           string
-	URL            string
+	URL            string // The Container the collection is bound to, see "pit remote set".
+	Profile        string `json:",omitempty"` // The profile of the Container on this computer when the collection was bound.
 	Documents      []documentProperties
+	Removed        []removedDocument `json:",omitempty"` // Removed with "pit rm" but not yet deleted remotely.
 }
 
 type documentProperties struct {
@@ -30,4 +36,15 @@ type documentProperties struct {
 	ETag		   string
 	MD5            string
 	PreviousMD5s   []string
-}
\ No newline at end of file
+	Absent         bool `json:",omitempty"` // Known but not downloaded (see "pit clone --only" and "pit fetch").
+	MovedFrom      string `json:",omitempty"` // Renamed with "pit mv" but not yet moved remotely.
+	Aliases        []string `json:",omitempty"` // Earlier names at which the remote document is still shared.
+}
+
+type removedDocument struct {
+	NameLocal      string
+	ETag           string // The remote document is only deleted if it still has this ETag.
+	MD5            string
+	Archive        bool   // Copy the remote document to the archive before deleting it.
+	Aliases        []string `json:",omitempty"`
+}
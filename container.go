@@ -21,70 +21,42 @@
 	Azure Go Resourced:
 	https://docs.microsoft.com/en-us/azure/developer/go/
 */
-package main
+package pit
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
 )
 
-func getContainerName() string {
-	containerName := os.Getenv("AZURE_CONTAINER_NAME")
-	if len(containerName) != 0 {
-		return containerName
-	}
-
-	account := new(accountProperties)
-	container, err := account.defaultContainer()
-	if err != nil {
-		log.Println("Fatal Error: Unable to obtain Container name")
-		return ""
-	}	
-	
-	return container.Name
+// The azureBackend stores documents as block blobs in an Azure Blob Storage Container.
+type azureBackend struct {
+	accountName   string
+	containerName string
+	containerURL  azblob.ContainerURL
 }
 
-func getDocumentNames(containerName string) []string {
-	containerURL, _ := getContainerURL(containerName)
-	ctx := context.Background() // This example uses a never-expiring context
-
-	var blobNames []string
-	for marker := (azblob.Marker{}); marker.NotDone(); {
-		// Get a result segment starting with the blob indicated by the current Marker.
-		listBlob, err := containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{})
-
-		// Check if ContainerNotFound
-		if err != nil {
-			if serr, ok := err.(azblob.StorageError); ok { // This error is a Service-specific
-				if serr.ServiceCode() == "ContainerNotFound" {
-					fmt.Printf("Container \"%s\" not found\n", containerName)
-					return blobNames
-				}
-			}
-		}
-	
-		handleAzureErrors(err)
-		marker = listBlob.NextMarker
-
-		// Process the blobs returned in this result segment (if the segment is empty, the loop body won't execute)
-		for _, blobInfo := range listBlob.Segment.BlobItems {
-			blobNames = append(blobNames, blobInfo.Name)
-		}
+func newAzureBackend(container containerProperties) (*azureBackend, error) {
+	account, err := getAzureAccount(container)
+	if err != nil {
+		return nil, err
 	}
 
-	return blobNames
-}
+	accountCredential, err := account.credential()
+	if err != nil {
+		return nil, err
+	}
 
-func uploadDocument(containerName string, localName string, remoteName string) {
-	accountName, _, accountCredential, err := getAccountNameKeyAndCredential()
 	p := azblob.NewPipeline(accountCredential, azblob.PipelineOptions{
 		Retry: azblob.RetryOptions{
 			TryTimeout: 120 * time.Minute,
@@ -100,213 +72,278 @@ func uploadDocument(containerName string, localName string, remoteName string) {
 	// based on the bandwidth available to the host machine and proximity to the Storage service. A good
 	// starting point may be something like (60 seconds per MB of anticipated-payload-size).
 
-	// From the Azure portal, get your storage account blob service URL endpoint.
-	URL, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s", accountName, containerName))
+	// The service URL is either the public Azure endpoint or a custom endpoint (e.g. Azurite or a sovereign cloud).
+	URL, err := url.Parse(account.serviceURL() + "/" + container.Name)
+	if err != nil {
+		return nil, err
+	}
 
-	containerURL := azblob.NewContainerURL(*URL, p)
-	ctx := context.Background() // This example uses a never-expiring context
+	backend := new(azureBackend)
+	backend.accountName = account.Name
+	backend.containerName = container.Name
+	backend.containerURL = azblob.NewContainerURL(*URL, p)
+	return backend, nil
+}
 
-	// Attempt to create a new container.
-	_, err = containerURL.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
-	handleAzureErrors(err)
+// Translates Azure service codes and status codes into ErrNotFound, ErrContainerMissing, ErrAuth, ErrThrottled, or
+// a ConflictError when the access conditions were not met.
+func azureError(err error, remoteName string) error {
+	if serr, ok := err.(azblob.StorageError); ok {
+		switch serr.ServiceCode() {
+		case azblob.ServiceCodeBlobNotFound:
+			return fmt.Errorf("%w: %s", ErrNotFound, serr.ServiceCode())
+		case azblob.ServiceCodeContainerNotFound:
+			return fmt.Errorf("%w: %s", ErrContainerMissing, serr.ServiceCode())
+		case azblob.ServiceCodeConditionNotMet, azblob.ServiceCodeBlobAlreadyExists:
+			return &ConflictError{RemoteName: remoteName, Err: errors.New(string(serr.ServiceCode()))}
+		case azblob.ServiceCodeAuthenticationFailed, azblob.ServiceCodeInsufficientAccountPermissions,
+			azblob.ServiceCodeAccountIsDisabled:
+			return fmt.Errorf("%w: %s", ErrAuth, serr.ServiceCode())
+		case azblob.ServiceCodeServerBusy:
+			return fmt.Errorf("%w: %s", ErrThrottled, serr.ServiceCode())
+		}
 
-	if localName != pitFileName {
-		fmt.Println(fmt.Sprintf("Uploading: %s...", localName))
-	} else {
-		log.Println(fmt.Sprintf("Uploading: %s...", localName))
+		// Responses to HEAD requests do not include a service code.
+		if serr.Response() != nil {
+			statusErr := httpStatusError(serr.Response().StatusCode)
+			if statusErr == ErrConflict {
+				return &ConflictError{RemoteName: remoteName, Err: errors.New(serr.Response().Status)}
+			} else if statusErr != nil {
+				return fmt.Errorf("%w: %s", statusErr, serr.Response().Status)
+			}
+		}
 	}
 
-	// Todo: Consider accessing the local file directly instead of creating a copy.
-	// Create a copy of the local file to upload.
-	copyFile(localName, remoteName)
+	return err
+}
+
+// Azure expects the ETag in access conditions to include the double quotes.
+func azureAccessConditions(conditions accessConditions) azblob.BlobAccessConditions {
+	var ac azblob.BlobAccessConditions
+	if len(conditions.IfMatch) != 0 {
+		ac.ModifiedAccessConditions.IfMatch = azblob.ETag("\"" + conditions.IfMatch + "\"")
+	}
+	if conditions.IfNoneMatch == etagAny {
+		ac.ModifiedAccessConditions.IfNoneMatch = azblob.ETagAny
+	}
+	return ac
+}
 
-	// Upload blob.
-	blobURL := containerURL.NewBlockBlobURL(remoteName)
-	file, err := os.Open(remoteName)
-	handleAzureErrors(err)
+func trimETag(etag azblob.ETag) string {
+	return strings.Trim(string(etag), "\"")
+}
+
+// Creates the container if it does not exist yet.
+func (ab *azureBackend) createContainer(ctx context.Context) error {
+	_, err := ab.containerURL.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
+	if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeContainerAlreadyExists {
+		log.Println("Verified container exists")
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ab *azureBackend) Put(ctx context.Context, localName string, remoteName string, conditions accessConditions) (string, error) {
+	// Attempt to create a new container.
+	err := ab.createContainer(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(localName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
 
 	// Set content types for known file extensions.
-	ext := filepath.Ext(remoteName)
 	o := azblob.UploadToBlockBlobOptions{
-		BlobHTTPHeaders: azblob.BlobHTTPHeaders{},
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{
+			ContentType: contentType(remoteName),
+		},
+		AccessConditions: azureAccessConditions(conditions),
 	}
 
-	if strings.EqualFold(ext, ".json") {
-		o = azblob.UploadToBlockBlobOptions{
-			BlobHTTPHeaders: azblob.BlobHTTPHeaders{
-				ContentType: "application/json",
-			},
-		}
-	} else if strings.EqualFold(ext, ".mp4") {
-		o = azblob.UploadToBlockBlobOptions{
-			BlobHTTPHeaders: azblob.BlobHTTPHeaders{
-				ContentType: "video/mp4",
-			},
-		}
-	} else if strings.EqualFold(ext, ".docx") {
-		o = azblob.UploadToBlockBlobOptions{
-			BlobHTTPHeaders: azblob.BlobHTTPHeaders{
-				ContentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
-			},
-		}
-	} else if strings.EqualFold(ext, ".pdf") {
-		o = azblob.UploadToBlockBlobOptions{
-			BlobHTTPHeaders: azblob.BlobHTTPHeaders{
-				ContentType: "application/pdf",
-			},
-		}
-	} else if strings.EqualFold(ext, ".html") {
-		o = azblob.UploadToBlockBlobOptions{
-			BlobHTTPHeaders: azblob.BlobHTTPHeaders{
-				ContentType: "text/html",
-			},
-		}
+	blobURL := ab.containerURL.NewBlockBlobURL(remoteName)
+	response, err := azblob.UploadFileToBlockBlob(ctx, file, blobURL, o)
+	if err != nil {
+		return "", azureError(err, remoteName)
 	}
-	
-	_, err = azblob.UploadFileToBlockBlob(ctx, file, blobURL, o)
-	handleAzureErrors(err)
+	return trimETag(response.ETag()), nil
+}
 
-	// Todo: Analyze if we should delete ctx by including the following statement?
-	//     containerURL.Delete(ctx, azblob.ContainerAccessConditions{})
+func (ab *azureBackend) StageBlock(ctx context.Context, remoteName string, blockID string, data []byte) error {
+	blockBlobURL := ab.containerURL.NewBlockBlobURL(remoteName)
 
-	// Delete the local file that we created above.
-	deleteFile(remoteName)
+	// The Content-MD5 of the block lets Azure reject a block that was corrupted in transit.
+	blockMD5 := md5.Sum(data)
+	_, err := blockBlobURL.StageBlock(ctx, blockID, bytes.NewReader(data), azblob.LeaseAccessConditions{}, blockMD5[:],
+		azblob.ClientProvidedKeyOptions{})
+	if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeContainerNotFound {
+		// As with Put, the container is created by the first upload.
+		err = ab.createContainer(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = blockBlobURL.StageBlock(ctx, blockID, bytes.NewReader(data), azblob.LeaseAccessConditions{}, blockMD5[:],
+			azblob.ClientProvidedKeyOptions{})
+	}
+	return azureError(err, remoteName)
 }
 
-/*
-func downloadDocument(containerName string, localName string, remoteName string) {
-	accountName, _, accountCredential, err:= getAccountNameKeyAndCredential()
-	fmt.Println("1")
-	fmt.Println(containerName)
-	fmt.Println(localName)
-	fmt.Println(remoteName)
-
-	p := azblob.NewPipeline(accountCredential, azblob.PipelineOptions{})
-	fmt.Println("2")
-	URL, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s", accountName, containerName))
-	fmt.Println("3")
-	containerURL := azblob.NewContainerURL(*URL, p)
-	ctx := context.Background() // This example uses a never-expiring context
-	fmt.Println("4")
-	blobURL := containerURL.NewBlockBlobURL(remoteName)
-	fmt.Println("5")
-	// Here's how to download the blob
-	downloadResponse, err := blobURL.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false)
-	fmt.Println("6")
-	handleAzureErrors(err)
+func (ab *azureBackend) StagedBlocks(ctx context.Context, remoteName string) ([]string, error) {
+	blockBlobURL := ab.containerURL.NewBlockBlobURL(remoteName)
 
-	// NOTE: automatically retries are performed if the connection fails
-	bodyStream := downloadResponse.Body(azblob.RetryReaderOptions{MaxRetryRequests: 20})
-	fmt.Printf("7")
-	// read the body into a buffer
-	downloadedData := bytes.Buffer{}
-	_, err = downloadedData.ReadFrom(bodyStream)
-	handleAzureErrors(err)
-	fmt.Printf("8")
-	// The downloaded blob data is in downloadData's buffer. :Let's print it
-	fmt.Printf("Downloaded the blob: " + downloadedData.String())
-
-	fmt.Printf("Cleaning up.\n")
-	// Careful: containerURL.Delete(ctx, azblob.ContainerAccessConditions{})
+	blockList, err := blockBlobURL.GetBlockList(ctx, azblob.BlockListUncommitted, azblob.LeaseAccessConditions{})
+	if err != nil {
+		return nil, azureError(err, remoteName)
+	}
+
+	var blockIDs []string
+	for _, block := range blockList.UncommittedBlocks {
+		blockIDs = append(blockIDs, block.Name)
+	}
+	return blockIDs, nil
 }
-*/
 
-func getFileBlobURL(containerName string, fileName string) azblob.BlobURL {
-	accountName, _, accountCredential, err := getAccountNameKeyAndCredential()
-	check(err)
-	URL, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s", accountName, containerName))
+func (ab *azureBackend) CommitBlocks(ctx context.Context, remoteName string, blockIDs []string, conditions accessConditions) (string, error) {
+	blockBlobURL := ab.containerURL.NewBlockBlobURL(remoteName)
 
-	p := azblob.NewPipeline(accountCredential, azblob.PipelineOptions{})
-	containerURL := azblob.NewContainerURL(*URL, p)
-	blobURL := containerURL.NewBlobURL(fileName)
-	return blobURL
+	response, err := blockBlobURL.CommitBlockList(ctx, blockIDs, azblob.BlobHTTPHeaders{ContentType: contentType(remoteName)},
+		azblob.Metadata{}, azureAccessConditions(conditions), azblob.AccessTierNone, nil, azblob.ClientProvidedKeyOptions{},
+		azblob.ImmutabilityPolicyOptions{})
+	if err != nil {
+		return "", azureError(err, remoteName)
+	}
+	return trimETag(response.ETag()), nil
 }
 
-func setDocumentMetadataMD5(containerName string, remoteName string, md5 string) {
-	blobURL := getFileBlobURL(containerName, remoteName)
-	ctx := context.Background() 
+func (ab *azureBackend) Get(ctx context.Context, remoteName string, offset int64, w io.Writer) error {
+	blobURL := ab.containerURL.NewBlobURL(remoteName)
 
-	blobProps, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
-	check(err)
+	downloadResponse, err := blobURL.Download(ctx, offset, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return azureError(err, remoteName)
+	}
 
-	// Add or update md5 property 
-	metadata := blobProps.NewMetadata()
-	metadata[pitMD5tag] = md5 
-	_, err = blobURL.SetMetadata(ctx, metadata, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
-	check(err)
+	// NOTE: automatically retries are performed if the connection fails
+	bodyStream := downloadResponse.Body(azblob.RetryReaderOptions{MaxRetryRequests: 20})
+	defer bodyStream.Close()
+
+	_, err = io.Copy(w, bodyStream)
+	return err
 }
 
-func getRemoteFileMD5AndETag(remoteFileName string) (string, string, error) {
-	MD5 := ""
-	ETag := ""
+func (ab *azureBackend) Stat(ctx context.Context, remoteName string) (remoteDocument, error) {
+	var remoteDoc remoteDocument
+	blobURL := ab.containerURL.NewBlobURL(remoteName)
 
-	metaData, err := getDocumentMetadata(getContainerName(),remoteFileName)
+	// Query the blob's properties and metadata.
+	blobProps, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
 	if err != nil {
-		return MD5, ETag, err
+		return remoteDoc, azureError(err, remoteName)
 	}
 
-	for _, element := range metaData {
-		// To view print all of the remote file's metadate, uncomment the following line: 
-		//     fmt.Println("metaDataElement: "+element)
-		if strings.Contains(element, pitMD5tag) {
-			strParts := strings.Split(element, "=")
-			MD5 = strParts[1]
-		}
+	// Consider utilizing Azure ETag property: 
+	//     References:
+	//     https://cann0nf0dder.wordpress.com/2015/09/07/azure-blob-storage-and-managing-concurrency/
 
-		if strings.Contains(element, "ETag=") {
-			strParts := strings.Split(element, "=")
+	// The ETag is returned as a string that includes double quotes so we need to remove the leading and trailing
+	// double quotes.
+	remoteDoc.Name = remoteName
+	remoteDoc.ETag = trimETag(blobProps.ETag())
+	remoteDoc.LastModified = blobProps.LastModified()
+	remoteDoc.Size = blobProps.ContentLength()
+	remoteDoc.Metadata = blobProps.NewMetadata()
+	remoteDoc.MD5 = remoteDoc.Metadata[pitMD5tag]
+	return remoteDoc, nil
+}
 
-			// The ETag is returned as a string that includes double quotes so we need to 
-			//     remove the leading and trailing double quotes.
-			ETag = strings.TrimLeft(strParts[1], "\"")
-			ETag = strings.TrimRight(ETag, "\"")
+func (ab *azureBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	var blobNames []string
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		// Get a result segment starting with the blob indicated by the current Marker.
+		listBlob, err := ab.containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{Prefix: prefix})
+		if err != nil {
+			err = azureError(err, "")
+			if errors.Is(err, ErrNotFound) {
+				log.Println(fmt.Sprintf("Container \"%s\" not found", ab.containerName))
+				return blobNames, nil
+			}
+			return blobNames, err
+		}
+		marker = listBlob.NextMarker
+
+		// Process the blobs returned in this result segment (if the segment is empty, the loop body won't execute)
+		for _, blobInfo := range listBlob.Segment.BlobItems {
+			blobNames = append(blobNames, blobInfo.Name)
 		}
 	}
 
-	return MD5, ETag, nil
+	return blobNames, nil
 }
 
-func getDocumentMetadata(containerName string, remoteName string) ([]string, error) {
-	blobURL := getFileBlobURL(containerName, remoteName)
-	ctx := context.Background() 
+func (ab *azureBackend) Delete(ctx context.Context, remoteName string, conditions accessConditions) error {
+	blobURL := ab.containerURL.NewBlobURL(remoteName)
 
-	// Query the blob's properties and metadata.
-	blobProps, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	_, err := blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azureAccessConditions(conditions))
+	return azureError(err, remoteName)
+}
+
+func (ab *azureBackend) Copy(ctx context.Context, sourceRemoteName string, remoteName string, conditions accessConditions) (string, error) {
+	blobURL := ab.containerURL.NewBlobURL(remoteName)
+
+	// Without metadata the metadata of the source blob is copied.
+	response, err := blobURL.StartCopyFromURL(ctx, ab.containerURL.NewBlobURL(sourceRemoteName).URL(), nil,
+		azblob.ModifiedAccessConditions{}, azureAccessConditions(conditions), azblob.AccessTierNone, nil)
 	if err != nil {
-		return nil, err
+		return "", azureError(err, remoteName)
+	}
+
+	// A copy within the storage account usually completes immediately, otherwise wait until it has completed.
+	status, etag := response.CopyStatus(), response.ETag()
+	for status == azblob.CopyStatusPending {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Second):
+		}
+		blobProps, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return "", azureError(err, remoteName)
+		}
+		status, etag = blobProps.CopyStatus(), blobProps.ETag()
 	}
 
-	var metadataElements []string
+	if status != azblob.CopyStatusSuccess {
+		return "", errors.New(fmt.Sprintf("Copy of %s to %s %s", sourceRemoteName, remoteName, status))
+	}
+	return trimETag(etag), nil
+}
 
-	// Add some of the Azure standard metadata.
-	metadataElements = append(metadataElements, string("BlogType="+blobProps.BlobType()))
-	metadataElements = append(metadataElements, string("ETag="+blobProps.ETag()))
-	metadataElements = append(metadataElements, string("LastModified="+blobProps.LastModified().Format(time.RFC3339)))
-	
-	// Consider utilizing Azures MD5 property:
-	//     fmt.Println("MD5=", get.ContentMD5())
+func (ab *azureBackend) SetMetadata(ctx context.Context, remoteName string, metadata map[string]string, conditions accessConditions) (string, error) {
+	blobURL := ab.containerURL.NewBlobURL(remoteName)
+	ac := azureAccessConditions(conditions)
 
-	// Consider utilizing Azure ETag property: 
-	//     References:
-	//     https://cann0nf0dder.wordpress.com/2015/09/07/azure-blob-storage-and-managing-concurrency/
+	blobProps, err := blobURL.GetProperties(ctx, ac, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return "", azureError(err, remoteName)
+	}
 
-	// Add application custom metadata.
-	metadata := blobProps.NewMetadata()
+	// Add or update the metadata while preserving existing values.
+	blobMetadata := blobProps.NewMetadata()
 	for k, v := range metadata {
-		metadataElements = append(metadataElements, k+"="+v)
+		blobMetadata[k] = v
+	}
+	response, err := blobURL.SetMetadata(ctx, blobMetadata, ac, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return "", azureError(err, remoteName)
 	}
-	return metadataElements, nil
+	return trimETag(response.ETag()), nil
 }
 
-func listBlobs(containerName string) {
-	documentNames := getDocumentNames(containerName)
-	if len(documentNames) == 0 {
-		fmt.Printf("No files currently in container \"%sd\"\n", containerName)		
-	} else {
-		fmt.Printf("Files in container \"%sd\":\n", containerName)
-		for _, documentName := range documentNames {
-			fmt.Println("    "+documentName)
-		}
-		fmt.Printf("Number of documents %d\n", len(documentNames))
-	}
-}
\ No newline at end of file
+func (ab *azureBackend) URL(remoteName string) string {
+	blobURL := ab.containerURL.NewBlobURL(remoteName).URL()
+	return blobURL.String()
+}
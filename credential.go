@@ -0,0 +1,366 @@
+/*
+	Credentials are the storage account keys, and S3 secret access keys, of the profiles. They are stored encrypted
+	in ~/.pit/credentials.json instead of in account.json: the keys are encrypted with AES-256-GCM using a key that
+	is derived with PBKDF2-HMAC-SHA256 from the passphrase, or from the key file named by the PIT_KEY_FILE
+	environment variable. Files that hold credentials are written with mode 0600 and pit refuses to use them when
+	the group or other users have any access to them.
+
+	Keys in the environment (e.g. AZURE_STORAGE_ACCESS_KEY) and keys that were stored in account.json before the
+	credential store existed take precedence, so the credential store is only unlocked when it is needed.
+*/
+package pit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Warning: Changing the names of the fields is a breaking change for existing credential files.
+type credentialsFile struct {
+	Description string
+	Iterations  int    // The PBKDF2 iterations.
+	Salt        string // Base64 encoded.
+	Nonce       string // Base64 encoded.
+	Data        string // The base64 encoded AES-GCM ciphertext of the JSON map from profile name to key.
+}
+
+const credentialsFileDescription = "Encrypted Credentials"
+
+// The PBKDF2 iterations of new credential files. A variable so that tests can use fewer iterations.
+var credentialIterations = 600000
+
+// Asks for the passphrase of the credential store, see Client.Passphrase.
+type passphraseFunc func(prompt string) (string, error)
+
+// The key derived from the passphrase, so that the passphrase is only asked for once.
+var credentialKey struct {
+	salt       string
+	iterations int
+	key        []byte
+}
+
+func credentialsFilePath() string {
+	return filepath.Join(new(accountProperties).userAppPath(), userAppCredentialsFileName)
+}
+
+// Returns an error if the file exists and its group or other users have any access to it. File modes are not
+// checked on Windows.
+func checkFilePrivate(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%w: %s holds credentials and is accessible by other users, use \"chmod 600 %s\"", ErrAuth,
+			path, path)
+	}
+	return nil
+}
+
+// Writes the file with mode 0600, also if the file already exists with another mode.
+func writePrivateFile(path string, data []byte) error {
+	err := ioutil.WriteFile(path, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// Returns true if the environment holds the credentials of the Container type, or if the type has none.
+func credentialsInEnvironment(containerType string) bool {
+	switch strings.ToLower(containerType) {
+	case "", "azure":
+		return len(os.Getenv("AZURE_STORAGE_CONNECTION_STRING")) != 0 ||
+			(len(os.Getenv("AZURE_STORAGE_ACCOUNT")) != 0 && len(os.Getenv("AZURE_STORAGE_ACCESS_KEY")) != 0)
+	case "s3":
+		return len(os.Getenv("AWS_ACCESS_KEY_ID")) != 0 && len(os.Getenv("AWS_SECRET_ACCESS_KEY")) != 0
+	}
+	return true
+}
+
+// Sets the Key of the Container from the credential store unless the key is already known. A key that is stored
+// in account.json is only used if other users cannot read account.json.
+func (cp *containerProperties) addCredential(passphrase passphraseFunc) error {
+	if credentialsInEnvironment(cp.Type) {
+		return nil
+	} else if len(cp.Key) != 0 {
+		return checkFilePrivate(new(accountProperties).filePathAndName())
+	} else if !fileExists(credentialsFilePath()) {
+		return nil
+	}
+
+	credentials, err := readCredentials(passphrase)
+	if err != nil {
+		return err
+	}
+	cp.Key = credentials[cp.profileName()]
+	return nil
+}
+
+// Returns the keys of the credential store by profile name, or an empty map if there is no credential store.
+func readCredentials(passphrase passphraseFunc) (map[string]string, error) {
+	credentials := map[string]string{}
+	path := credentialsFilePath()
+	if !fileExists(path) {
+		return credentials, nil
+	}
+	err := checkFilePrivate(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file credentialsFile
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil || file.Description != credentialsFileDescription {
+		return nil, errors.New(fmt.Sprintf("Credential file %s not valid.", path))
+	}
+	salt, err := base64.StdEncoding.DecodeString(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(file.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(file.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := deriveCredentialKey(salt, file.Iterations, false, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newCredentialCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(credentialsFileDescription))
+	if err != nil {
+		credentialKey.key = nil
+		return nil, fmt.Errorf("%w: wrong passphrase or key file for %s", ErrAuth, path)
+	}
+
+	err = json.Unmarshal(plaintext, &credentials)
+	return credentials, err
+}
+
+// Encrypts and writes the keys. A new credential store is encrypted with a new passphrase.
+func writeCredentials(credentials map[string]string, passphrase passphraseFunc) error {
+	var salt []byte
+	iterations := credentialIterations
+	if fileExists(credentialsFilePath()) && credentialKey.key != nil {
+		// The credential store was read before, so keep its passphrase.
+		salt, _ = base64.StdEncoding.DecodeString(credentialKey.salt)
+		iterations = credentialKey.iterations
+	} else {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+
+	key, err := deriveCredentialKey(salt, iterations, true, passphrase)
+	if err != nil {
+		return err
+	}
+	aead, err := newCredentialCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+
+	file := credentialsFile{Description: credentialsFileDescription, Iterations: iterations,
+		Salt: base64.StdEncoding.EncodeToString(salt), Nonce: base64.StdEncoding.EncodeToString(nonce),
+		Data: base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, []byte(credentialsFileDescription)))}
+	data, err := json.MarshalIndent(file, "", "    ")
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(credentialsFilePath(), data)
+}
+
+func newCredentialCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Returns the key for the salt, asking for the passphrase, or a new passphrase if create is true, unless the key
+// was derived before.
+func deriveCredentialKey(salt []byte, iterations int, create bool, passphrase passphraseFunc) ([]byte, error) {
+	encodedSalt := base64.StdEncoding.EncodeToString(salt)
+	if credentialKey.key != nil && credentialKey.salt == encodedSalt && credentialKey.iterations == iterations {
+		return credentialKey.key, nil
+	}
+	if iterations < 1 {
+		return nil, errors.New("Credential file not valid.")
+	}
+
+	secret, err := credentialSecret(create, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	credentialKey.salt = encodedSalt
+	credentialKey.iterations = iterations
+	credentialKey.key = pbkdf2.Key(secret, salt, iterations, 32, sha256.New)
+	return credentialKey.key, nil
+}
+
+// Returns the content of the PIT_KEY_FILE key file, otherwise asks for the passphrase.
+func credentialSecret(create bool, passphrase passphraseFunc) ([]byte, error) {
+	if keyFile := os.Getenv("PIT_KEY_FILE"); len(keyFile) != 0 {
+		err := checkFilePrivate(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		secret, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		secret = []byte(strings.TrimSpace(string(secret)))
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("%w: the key file %s is empty", ErrAuth, keyFile)
+		}
+		return secret, nil
+	}
+
+	if passphrase == nil {
+		return nil, fmt.Errorf("%w: the credentials are locked, set the PIT_KEY_FILE environment variable or enter "+
+			"the passphrase", ErrAuth)
+	}
+	if !create {
+		secret, err := passphrase("Passphrase: ")
+		return []byte(secret), err
+	}
+
+	secret, err := passphrase("New passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("The passphrase must not be empty")
+	}
+	repeated, err := passphrase("Repeat passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if repeated != secret {
+		return nil, errors.New("The passphrases do not match")
+	}
+	return []byte(secret), nil
+}
+
+// Runs f while no operation runs, so that the credential store is not read and written at the same time.
+func (client *Client) withCredentials(f func() error) error {
+	operationMutex.Lock()
+	defer operationMutex.Unlock()
+
+	return f()
+}
+
+// SetCredential stores the key of the profile in the credential store, which is created if needed. A key of the
+// profile in account.json is removed from account.json.
+func (client *Client) SetCredential(profile string, key string) error {
+	if len(key) == 0 {
+		return errors.New("The key must not be empty")
+	}
+
+	return client.withCredentials(func() error {
+		account := new(accountProperties)
+		err := account.read()
+		if err != nil {
+			return err
+		}
+		index := account.profileIndex(profile)
+		if index < 0 {
+			return fmt.Errorf("%w: profile \"%s\"", ErrNotFound, profile)
+		}
+
+		credentials, err := readCredentials(client.Passphrase)
+		if err != nil {
+			return err
+		}
+		credentials[profile] = key
+		err = writeCredentials(credentials, client.Passphrase)
+		if err != nil {
+			return err
+		}
+
+		if len(account.Containers[index].Key) != 0 {
+			account.Containers[index].Key = ""
+			return account.write()
+		}
+		return nil
+	})
+}
+
+// RemoveCredential removes the key of the profile from the credential store and from account.json.
+func (client *Client) RemoveCredential(profile string) error {
+	return client.withCredentials(func() error {
+		account := new(accountProperties)
+		err := account.read()
+		if err != nil {
+			return err
+		}
+		removed := false
+		index := account.profileIndex(profile)
+		if index >= 0 && len(account.Containers[index].Key) != 0 {
+			account.Containers[index].Key = ""
+			err = account.write()
+			if err != nil {
+				return err
+			}
+			removed = true
+		}
+
+		if fileExists(credentialsFilePath()) {
+			credentials, err := readCredentials(client.Passphrase)
+			if err != nil {
+				return err
+			}
+			if _, ok := credentials[profile]; ok {
+				delete(credentials, profile)
+				err = writeCredentials(credentials, client.Passphrase)
+				if err != nil {
+					return err
+				}
+				removed = true
+			}
+		}
+
+		if !removed {
+			return fmt.Errorf("%w: credential of profile \"%s\"", ErrNotFound, profile)
+		}
+		return nil
+	})
+}
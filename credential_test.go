@@ -0,0 +1,188 @@
+package pit
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentials(t *testing.T) {
+	te := newTestEnvironment(t)
+	iterations := credentialIterations
+	credentialIterations = 1000
+	t.Cleanup(func() {
+		credentialIterations = iterations
+		credentialKey.key = nil
+	})
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+
+	keyFile := filepath.Join(te.root, "pit.key")
+	if err := ioutil.WriteFile(keyFile, []byte("key file secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PIT_KEY_FILE", keyFile)
+
+	// The key of a new profile is stored in the credential store instead of in account.json.
+	client := new(Client)
+	if err := client.AddProfile(Profile{Name: "s3", Type: "s3", Account: "AKID", Key: "secret", Container: "bucket"}); err != nil {
+		t.Fatal(err)
+	}
+	account := new(accountProperties)
+	if err := account.read(); err != nil || len(account.Containers[1].Key) != 0 {
+		t.Fatalf("expected no key in account.json, got %+v, %v", account.Containers, err)
+	}
+	for _, name := range []string{account.filePathAndName(), credentialsFilePath()} {
+		if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("expected mode 0600 for %s, got %v", name, info.Mode())
+		}
+	}
+
+	credentialKey.key = nil
+	backend, err := newContainerBackend(account.Containers[1], nil)
+	if err != nil || backend.(*s3Backend).secretKey != "secret" {
+		t.Fatalf("expected the key from the credential store, got %v", err)
+	}
+
+	// The credential store is not unlocked with another key file, or when other users can access it.
+	if err := ioutil.WriteFile(keyFile, []byte("another secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	credentialKey.key = nil
+	if _, err := newContainerBackend(account.Containers[1], nil); !errors.Is(err, ErrAuth) {
+		t.Errorf("expected ErrAuth for the wrong key file, got %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, []byte("key file secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []os.FileMode{0644, 0640, 0620, 0602} {
+		if err := os.Chmod(credentialsFilePath(), mode); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newContainerBackend(account.Containers[1], nil); !errors.Is(err, ErrAuth) {
+			t.Errorf("expected ErrAuth for a credential file with mode %o, got %v", mode, err)
+		}
+	}
+	if err := os.Chmod(credentialsFilePath(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.RemoveCredential("s3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveCredential("s3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// A new credential store is encrypted with the passphrase if there is no key file.
+	if err := os.Remove(credentialsFilePath()); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PIT_KEY_FILE", "")
+	credentialKey.key = nil
+	if err := client.SetCredential("s3", "new secret"); !errors.Is(err, ErrAuth) {
+		t.Errorf("expected ErrAuth without a passphrase, got %v", err)
+	}
+	client.Passphrase = func(prompt string) (string, error) { return "passphrase", nil }
+	if err := client.SetCredential("s3", "new secret"); err != nil {
+		t.Fatal(err)
+	}
+	credentialKey.key = nil
+	backend, err = newContainerBackend(account.Containers[1], client.Passphrase)
+	if err != nil || backend.(*s3Backend).secretKey != "new secret" {
+		t.Fatalf("expected the new key from the credential store, got %v", err)
+	}
+
+	// Removing the profile also removes its key.
+	if err := client.RemoveProfile("s3"); err != nil {
+		t.Fatal(err)
+	}
+	if credentials, err := readCredentials(client.Passphrase); err != nil || len(credentials) != 0 {
+		t.Errorf("expected an empty credential store, got %d keys, %v", len(credentials), err)
+	}
+}
+
+func TestWorldReadableAccountKeys(t *testing.T) {
+	newTestEnvironment(t)
+	account := new(accountProperties)
+	if err := os.Chmod(account.filePathAndName(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The Container of the test environment has its key in account.json, which is only checked when the key is
+	// used, so that e.g. "pit version" and "pit profile list" still work.
+	if err := account.read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newContainerBackend(account.Containers[0], nil); !errors.Is(err, ErrAuth) {
+		t.Errorf("expected ErrAuth for keys in a world-readable account.json, got %v", err)
+	}
+}
+
+// A collection bound to one profile does not unlock the credential store because of the keys of other profiles.
+func TestCredentialsOnlyUnlockedWhenNeeded(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdirComputer("computer1")
+	iterations := credentialIterations
+	credentialIterations = 1000
+	t.Cleanup(func() {
+		credentialIterations = iterations
+		credentialKey.key = nil
+	})
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	ctx := context.Background()
+
+	client := new(Client)
+	client.Passphrase = func(prompt string) (string, error) { return "passphrase", nil }
+	if err := client.AddProfile(Profile{Name: "s3", Type: "s3", Account: "AKID", Key: "secret", Container: "bucket",
+		Endpoint: "http://127.0.0.1:9"}); err != nil {
+		t.Fatal(err)
+	}
+	nas := filepath.Join(te.root, "nas")
+	if err := client.AddProfile(Profile{Name: "nas", Type: "file", Path: nas, Container: "videos"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.UseProfile("nas"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The URL of every type of Container is known without its credentials.
+	account := new(accountProperties)
+	if err := account.read(); err != nil {
+		t.Fatal(err)
+	}
+	for _, container := range account.Containers {
+		containerURL, err := container.containerURL()
+		credentialKey.key = nil
+		backend, backendErr := newContainerBackend(container, client.Passphrase)
+		if err != nil || backendErr != nil || containerURL != backendContainerURL(backend) {
+			t.Errorf("unexpected URL %s of %s, %v %v", containerURL, container.profileName(), err, backendErr)
+		}
+	}
+
+	credentialKey.key = nil
+	client.Passphrase = func(prompt string) (string, error) {
+		t.Errorf("asked for the passphrase")
+		return "", errors.New("no passphrase")
+	}
+	collection, err := client.Init(ctx, "videos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(collection.Dir(), "intro.mp4"), []byte("intro"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := collection.Add(ctx, "intro.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := collection.Push(ctx, PushOptions{}); err != nil || report.Failed {
+		t.Fatalf("unexpected push report %+v, %v", report, err)
+	}
+	if report, err := collection.Status(ctx); err != nil || report.Summary[StateVerified] != 1 {
+		t.Fatalf("unexpected status report %+v, %v", report, err)
+	}
+}
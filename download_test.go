@@ -0,0 +1,129 @@
+package pit
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func md5String(s string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+}
+
+// Pushes intro.mp4 and returns its remote name.
+func setupDownload(t *testing.T, te *testEnvironment, content string) string {
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", content)
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	props := readTestCollection(t)
+	te.chdirComputer("computer2")
+	return getRemoteFileName(props, "intro.mp4")
+}
+
+func TestDownloadDocumentResumes(t *testing.T) {
+	te := newTestEnvironment(t)
+	remoteName := setupDownload(t, te, "the whole video")
+	writeTestFile(t, "intro.mp4.tmp", "the who")
+
+	captureOutput(t, func() {
+		if err := downloadDocument(te.op, te.backend(t), remoteName, "intro.mp4", md5String("the whole video")); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if readTestFile(t, "intro.mp4") != "the whole video" {
+		t.Errorf("unexpected content %q", readTestFile(t, "intro.mp4"))
+	}
+	if te.server.lastDownloadRange() != "bytes=7-" {
+		t.Errorf("download was not resumed, range %q", te.server.lastDownloadRange())
+	}
+	if fileExists("intro.mp4.tmp") {
+		t.Errorf("temporary file was not removed")
+	}
+}
+
+func TestDownloadDocumentRestartsMismatchedResume(t *testing.T) {
+	te := newTestEnvironment(t)
+	remoteName := setupDownload(t, te, "the whole video")
+	writeTestFile(t, "intro.mp4.tmp", "an older")
+
+	// The expected MD5 is unknown, so the download is verified against the pitmd5 metadata.
+	captureOutput(t, func() {
+		if err := downloadDocument(te.op, te.backend(t), remoteName, "intro.mp4", ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if readTestFile(t, "intro.mp4") != "the whole video" {
+		t.Errorf("unexpected content %q", readTestFile(t, "intro.mp4"))
+	}
+}
+
+func TestDownloadDocumentRejectsUnverifiedContent(t *testing.T) {
+	te := newTestEnvironment(t)
+	remoteName := setupDownload(t, te, "the whole video")
+
+	// The remote document was replaced without updating its pitmd5 metadata.
+	backend := te.backend(t)
+	writeTestFile(t, "replacement.mp4", "a replacement")
+	if _, err := backend.Put(context.Background(), "replacement.mp4", remoteName, accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.SetMetadata(context.Background(), remoteName, map[string]string{pitMD5tag: md5String("the whole video")}, accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	captureOutput(t, func() { err = downloadDocument(te.op, backend, remoteName, "intro.mp4", md5String("the whole video")) })
+	if err == nil || !strings.Contains(err.Error(), "downloaded file has MD5") {
+		t.Errorf("expected an MD5 error, got %v", err)
+	}
+	if fileExists("intro.mp4") || fileExists("intro.mp4.tmp") {
+		t.Errorf("unverified download was kept")
+	}
+}
+
+func TestCloneResumes(t *testing.T) {
+	te := newTestEnvironment(t)
+	setupDownload(t, te, "the whole video")
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{}) })
+	te.chdirCloned()
+
+	// Simulate a clone that was interrupted while downloading intro.mp4.
+	os.Remove("intro.mp4")
+	writeTestFile(t, "intro.mp4.tmp", "the who")
+	te.chdirComputer("computer2")
+
+	output := captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{}) })
+	te.chdirCloned()
+	assertContains(t, output, "Resuming clone of \"videos\"")
+	if readTestFile(t, "intro.mp4") != "the whole video" {
+		t.Errorf("clone was not resumed")
+	}
+	if te.server.lastDownloadRange() != "bytes=7-" {
+		t.Errorf("download was not resumed, range %q", te.server.lastDownloadRange())
+	}
+}
+
+func TestDownloadDocumentCancelled(t *testing.T) {
+	te := newTestEnvironment(t)
+	remoteName := setupDownload(t, te, "the whole video")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var err error
+	captureOutput(t, func() {
+		err = downloadDocument(&operation{ctx: ctx, dir: te.op.dir, out: stdoutWriter{}}, te.backend(t), remoteName, "intro.mp4", md5String("the whole video"))
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the download to be cancelled, got %v", err)
+	}
+	if fileExists("intro.mp4") {
+		t.Errorf("cancelled download was kept")
+	}
+}
@@ -0,0 +1,100 @@
+/*
+	Errors returned by a Backend, and by the operations of the API, are classified with the sentinel errors below so
+	that callers can use errors.Is() instead of matching service codes or error messages, e.g.:
+
+		report, err := collection.Push(ctx, pit.PushOptions{})
+		if errors.Is(err, pit.ErrThrottled) {
+			// Try again later.
+		}
+
+	A ConflictError can be inspected with errors.As() for the name of the remote document. Each class of error has
+	its own exit code of the pit command, see ExitCode().
+*/
+package pit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotFound is returned when a remote document, collection, or Container does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrContainerMissing is returned when the Container (or S3 bucket) does not exist. It is also an ErrNotFound.
+var ErrContainerMissing = fmt.Errorf("container %w", ErrNotFound)
+
+// ErrConflict is returned when a remote document was changed, or created, by another computer since its ETag was
+// recorded.
+var ErrConflict = errors.New("changed by another computer")
+
+// ErrAuth is returned when credentials are missing or are rejected by the Container.
+var ErrAuth = errors.New("authentication failed")
+
+// ErrThrottled is returned when the Container is busy or rejects requests because too many are made.
+var ErrThrottled = errors.New("request throttled")
+
+// A ConflictError is returned by a Backend when the access conditions of a request are not met, i.e. the remote
+// document was changed (or created) by another computer since its ETag was recorded. It is an ErrConflict.
+type ConflictError struct {
+	RemoteName string
+	Err        error
+}
+
+func (ce *ConflictError) Error() string {
+	return fmt.Sprintf("%s was changed by another computer (%s)", ce.RemoteName, ce.Err)
+}
+
+func (ce *ConflictError) Unwrap() error {
+	return ce.Err
+}
+
+func (ce *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Exit codes of the pit command.
+const (
+	ExitSuccess          = 0
+	ExitFailure          = 1 // Any other error, or a document that could not be pushed or downloaded.
+	ExitUsage            = 2 // Unknown command or invalid arguments.
+	ExitNotFound         = 3
+	ExitConflict         = 4
+	ExitAuth             = 5
+	ExitContainerMissing = 6
+	ExitThrottled        = 7
+)
+
+// ExitCode returns the exit code of the pit command for the error.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.Is(err, ErrContainerMissing):
+		return ExitContainerMissing
+	case errors.Is(err, ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, ErrConflict):
+		return ExitConflict
+	case errors.Is(err, ErrAuth):
+		return ExitAuth
+	case errors.Is(err, ErrThrottled):
+		return ExitThrottled
+	}
+	return ExitFailure
+}
+
+// Classifies an HTTP error response by its status code. Returns nil for other status codes.
+func httpStatusError(statusCode int) error {
+	switch statusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusPreconditionFailed:
+		return ErrConflict
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuth
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return ErrThrottled
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package pit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, ExitSuccess},
+		{errors.New("disk full"), ExitFailure},
+		{fmt.Errorf("%w: BlobNotFound", ErrNotFound), ExitNotFound},
+		{fmt.Errorf("%w: ContainerNotFound", ErrContainerMissing), ExitContainerMissing},
+		{&ConflictError{RemoteName: "intro.mp4", Err: errors.New("ETag does not match")}, ExitConflict},
+		{fmt.Errorf("%w: AuthenticationFailed", ErrAuth), ExitAuth},
+		{&s3Error{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown"}, ExitThrottled},
+	}
+	for _, test := range tests {
+		if code := ExitCode(test.err); code != test.code {
+			t.Errorf("ExitCode(%v) = %d, expected %d", test.err, code, test.code)
+		}
+	}
+}
+
+func TestS3ErrorIs(t *testing.T) {
+	noSuchBucket := &s3Error{StatusCode: http.StatusNotFound, Code: "NoSuchBucket"}
+	if !errors.Is(noSuchBucket, ErrContainerMissing) || !errors.Is(noSuchBucket, ErrNotFound) {
+		t.Errorf("%s is not ErrContainerMissing and ErrNotFound", noSuchBucket)
+	}
+	noSuchKey := &s3Error{StatusCode: http.StatusNotFound, Code: "NoSuchKey"}
+	if !errors.Is(noSuchKey, ErrNotFound) || errors.Is(noSuchKey, ErrContainerMissing) {
+		t.Errorf("%s is not only ErrNotFound", noSuchKey)
+	}
+	if accessDenied := (&s3Error{StatusCode: http.StatusForbidden, Code: "AccessDenied"}); !errors.Is(accessDenied, ErrAuth) {
+		t.Errorf("%s is not ErrAuth", accessDenied)
+	}
+
+	conflict := s3ConditionError(&s3Error{StatusCode: http.StatusPreconditionFailed}, "intro.mp4")
+	var conflictErr *ConflictError
+	if !errors.Is(conflict, ErrConflict) || !errors.As(conflict, &conflictErr) || conflictErr.RemoteName != "intro.mp4" {
+		t.Errorf("%s is not a ConflictError", conflict)
+	}
+}
+
+func TestS3StatMissingBucket(t *testing.T) {
+	bucketExists := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/videos" && bucketExists {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := newS3Backend(containerProperties{Type: "s3", Account: "AKID", Key: "secret", Name: "videos",
+		Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Stat(context.Background(), "intro.mp4"); !errors.Is(err, ErrNotFound) || errors.Is(err, ErrContainerMissing) {
+		t.Errorf("expected only ErrNotFound for a missing object, got %v", err)
+	}
+	bucketExists = false
+	if _, err := backend.Stat(context.Background(), "intro.mp4"); !errors.Is(err, ErrContainerMissing) {
+		t.Errorf("expected ErrContainerMissing for a missing bucket, got %v", err)
+	}
+}
+
+func TestURLBackendErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/videos/") {
+		case "private.mp4":
+			w.WriteHeader(http.StatusForbidden)
+		case "busy.mp4":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend := newURLBackend(server.URL + "/videos/")
+	expected := map[string]error{"private.mp4": ErrAuth, "busy.mp4": ErrThrottled, "missing.mp4": ErrNotFound}
+	for name, expectedErr := range expected {
+		if _, err := backend.Stat(context.Background(), name); !errors.Is(err, expectedErr) {
+			t.Errorf("Stat(%s) returned %v, expected %v", name, err, expectedErr)
+		}
+	}
+}
+
+func TestPushWithoutCredentialsReturnsErrAuth(t *testing.T) {
+	var container containerProperties
+	container.Type = "azure"
+	container.Name = testContainerName
+	container.Default = "yes"
+	te := newTestEnvironmentWithContainer(t, container)
+	te.chdirComputer("computer1")
+
+	client := new(Client)
+	collection, err := client.Init(context.Background(), "videos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collection.Push(context.Background(), PushOptions{}); !errors.Is(err, ErrAuth) || ExitCode(err) != ExitAuth {
+		t.Errorf("expected ErrAuth, got %v", err)
+	}
+}
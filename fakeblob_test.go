@@ -0,0 +1,456 @@
+package pit
+
+// An in-process fake of the subset of the Azure Blob Storage REST API that pit uses. The fake serves path-style
+// URLs (http://127.0.0.1:<port>/<account>/<container>/<blob>) in the same way as the Azurite emulator.
+//
+// Reference: https://docs.microsoft.com/en-us/rest/api/storageservices/blob-service-rest-api
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fakeBlob struct {
+	data         []byte
+	contentType  string
+	metadata     map[string]string
+	etag         string
+	lastModified time.Time
+}
+
+type fakeBlobServer struct {
+	server     *httptest.Server
+	mutex      sync.Mutex
+	containers map[string]map[string]*fakeBlob
+	staged     map[string]map[string][]byte // Staged blocks by "<container>/<blob>" and block ID.
+	etagCount  int
+	stageCount int    // The number of blocks staged so far.
+	md5Count   int    // The number of blocks staged with a Content-MD5 header.
+	lastRange  string // The range of the last blob download, e.g. "bytes=10-".
+	public     bool   // True if blobs can be read without authorization, as in a public container.
+}
+
+func newFakeBlobServer() *fakeBlobServer {
+	fs := new(fakeBlobServer)
+	fs.containers = map[string]map[string]*fakeBlob{}
+	fs.staged = map[string]map[string][]byte{}
+	fs.server = httptest.NewServer(http.HandlerFunc(fs.serveHTTP))
+	return fs
+}
+
+func (fs *fakeBlobServer) close() {
+	fs.server.Close()
+}
+
+// Returns the blob service endpoint of the account (e.g. "http://127.0.0.1:1234/devstoreaccount1").
+func (fs *fakeBlobServer) endpoint() string {
+	return fs.server.URL + "/" + azuriteAccountName
+}
+
+// Returns a copy of the blob, or nil if the blob does not exist.
+func (fs *fakeBlobServer) blob(containerName string, blobName string) *fakeBlob {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	blob := fs.containers[containerName][blobName]
+	if blob == nil {
+		return nil
+	}
+	copied := *blob
+	return &copied
+}
+
+func (fs *fakeBlobServer) blobNames(containerName string) []string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var names []string
+	for name := range fs.containers[containerName] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (fs *fakeBlobServer) stagedBlockCount() int {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.stageCount
+}
+
+// Returns the number of blocks staged with a Content-MD5 header that matched their content.
+func (fs *fakeBlobServer) verifiedBlockCount() int {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.md5Count
+}
+
+// Allows blobs to be read without authorization.
+func (fs *fakeBlobServer) allowAnonymousRead() {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.public = true
+}
+
+func (fs *fakeBlobServer) lastDownloadRange() string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.lastRange
+}
+
+func (fs *fakeBlobServer) newETag() string {
+	fs.etagCount++
+	return fmt.Sprintf("\"0x8D9%012X\"", fs.etagCount)
+}
+
+func writeFakeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>%s</Code><Message>%s</Message></Error>",
+			code, code)
+	}
+}
+
+func writeBlobHeaders(w http.ResponseWriter, blob *fakeBlob) {
+	w.Header().Set("ETag", blob.etag)
+	w.Header().Set("Last-Modified", blob.lastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("x-ms-blob-type", "BlockBlob")
+	if len(blob.contentType) != 0 {
+		w.Header().Set("Content-Type", blob.contentType)
+	}
+	for k, v := range blob.metadata {
+		w.Header().Set("x-ms-meta-"+k, v)
+	}
+}
+
+func requestMetadata(r *http.Request) map[string]string {
+	metadata := map[string]string{}
+	for name, values := range r.Header {
+		lowerName := strings.ToLower(name)
+		if strings.HasPrefix(lowerName, "x-ms-meta-") && len(values) > 0 {
+			metadata[strings.TrimPrefix(lowerName, "x-ms-meta-")] = values[0]
+		}
+	}
+	return metadata
+}
+
+// Writes an error and returns false if the blob does not match the If-Match and If-None-Match headers.
+func checkFakeConditions(w http.ResponseWriter, r *http.Request, blob *fakeBlob) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if len(ifMatch) != 0 && (blob == nil || (ifMatch != "*" && ifMatch != blob.etag)) {
+		writeFakeError(w, r, http.StatusPreconditionFailed, "ConditionNotMet")
+		return false
+	}
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if len(ifNoneMatch) != 0 && blob != nil && (ifNoneMatch == "*" || ifNoneMatch == blob.etag) {
+		if r.Method == http.MethodPut {
+			writeFakeError(w, r, http.StatusConflict, "BlobAlreadyExists")
+		} else {
+			writeFakeError(w, r, http.StatusPreconditionFailed, "ConditionNotMet")
+		}
+		return false
+	}
+	return true
+}
+
+// Replaces the ETag of the blob as if it had been updated by another computer.
+func (fs *fakeBlobServer) touch(containerName string, blobName string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if blob := fs.containers[containerName][blobName]; blob != nil {
+		blob.etag = fs.newETag()
+	}
+}
+
+func (fs *fakeBlobServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	anonymousRead := fs.public && (r.Method == http.MethodGet || r.Method == http.MethodHead) && len(r.URL.RawQuery) == 0
+	if !anonymousRead && !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey "+azuriteAccountName+":") {
+		writeFakeError(w, r, http.StatusForbidden, "AuthenticationFailed")
+		return
+	}
+
+	// The path is /<account>/<container>[/<blob>].
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] != azuriteAccountName {
+		writeFakeError(w, r, http.StatusBadRequest, "InvalidUri")
+		return
+	}
+
+	containerName := parts[1]
+	query := r.URL.Query()
+	if len(parts) == 2 || len(parts[2]) == 0 {
+		fs.serveContainer(w, r, containerName, query.Get("comp"))
+		return
+	}
+
+	blobs, ok := fs.containers[containerName]
+	if !ok {
+		writeFakeError(w, r, http.StatusNotFound, "ContainerNotFound")
+		return
+	}
+
+	blobName := parts[2]
+	blob := blobs[blobName]
+	if (r.Method != http.MethodDelete || blob != nil) && !checkFakeConditions(w, r, blob) {
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPut && query.Get("comp") == "metadata":
+		if blob == nil {
+			writeFakeError(w, r, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		blob.metadata = requestMetadata(r)
+		blob.etag = fs.newETag()
+		blob.lastModified = time.Now()
+		w.Header().Set("ETag", blob.etag)
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeFakeError(w, r, http.StatusBadRequest, "InvalidInput")
+			return
+		}
+		if contentMD5 := r.Header.Get("Content-MD5"); len(contentMD5) != 0 {
+			sum := md5.Sum(data)
+			if contentMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+				writeFakeError(w, r, http.StatusBadRequest, "Md5Mismatch")
+				return
+			}
+			fs.md5Count++
+		}
+		stagedKey := containerName + "/" + blobName
+		if fs.staged[stagedKey] == nil {
+			fs.staged[stagedKey] = map[string][]byte{}
+		}
+		fs.staged[stagedKey][query.Get("blockid")] = data
+		fs.stageCount++
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodGet && query.Get("comp") == "blocklist":
+		stagedKey := containerName + "/" + blobName
+		if blob == nil && len(fs.staged[stagedKey]) == 0 {
+			writeFakeError(w, r, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		var result fakeBlockList
+		for blockID, data := range fs.staged[stagedKey] {
+			result.UncommittedBlocks = append(result.UncommittedBlocks, fakeBlock{Name: blockID, Size: len(data)})
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).Encode(result)
+
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var blockList struct {
+			BlockIDs []string `xml:",any"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&blockList); err != nil {
+			writeFakeError(w, r, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+		stagedKey := containerName + "/" + blobName
+		var data []byte
+		for _, blockID := range blockList.BlockIDs {
+			block, ok := fs.staged[stagedKey][blockID]
+			if !ok {
+				writeFakeError(w, r, http.StatusBadRequest, "InvalidBlockList")
+				return
+			}
+			data = append(data, block...)
+		}
+		delete(fs.staged, stagedKey)
+		blob = &fakeBlob{data: data, contentType: r.Header.Get("x-ms-blob-content-type"),
+			metadata: requestMetadata(r), etag: fs.newETag(), lastModified: time.Now()}
+		blobs[blobName] = blob
+		w.Header().Set("ETag", blob.etag)
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodPut && len(r.Header.Get("x-ms-copy-source")) != 0:
+		// Only copies within the account are supported, e.g. .../<account>/<container>/<blob>.
+		sourceURL, err := url.Parse(r.Header.Get("x-ms-copy-source"))
+		sourceParts := strings.SplitN(strings.TrimPrefix(sourceURL.Path, "/"), "/", 3)
+		if err != nil || len(sourceParts) != 3 || sourceParts[0] != azuriteAccountName {
+			writeFakeError(w, r, http.StatusBadRequest, "InvalidSourceBlobUrl")
+			return
+		}
+		source := fs.containers[sourceParts[1]][sourceParts[2]]
+		if source == nil {
+			writeFakeError(w, r, http.StatusNotFound, "CannotVerifyCopySource")
+			return
+		}
+		metadata := map[string]string{}
+		for k, v := range source.metadata {
+			metadata[k] = v
+		}
+		blob = &fakeBlob{data: source.data, contentType: source.contentType, metadata: metadata, etag: fs.newETag(),
+			lastModified: time.Now()}
+		blobs[blobName] = blob
+		w.Header().Set("ETag", blob.etag)
+		w.Header().Set("x-ms-copy-status", "success")
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeFakeError(w, r, http.StatusBadRequest, "InvalidInput")
+			return
+		}
+		delete(fs.staged, containerName+"/"+blobName)
+		blob = &fakeBlob{data: data, contentType: r.Header.Get("x-ms-blob-content-type"),
+			metadata: requestMetadata(r), etag: fs.newETag(), lastModified: time.Now()}
+		blobs[blobName] = blob
+		w.Header().Set("ETag", blob.etag)
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		if blob == nil {
+			writeFakeError(w, r, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		writeBlobHeaders(w, blob)
+		data, status := blob.data, http.StatusOK
+		if r.Method == http.MethodGet {
+			fs.lastRange = r.Header.Get("x-ms-range")
+			var offset int
+			if _, err := fmt.Sscanf(fs.lastRange, "bytes=%d-", &offset); err == nil {
+				if offset >= len(data) {
+					writeFakeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+					return
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(data)-1, len(data)))
+				data, status = data[offset:], http.StatusPartialContent
+			}
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case r.Method == http.MethodDelete:
+		if blob == nil {
+			writeFakeError(w, r, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(blobs, blobName)
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		writeFakeError(w, r, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+func (fs *fakeBlobServer) serveContainer(w http.ResponseWriter, r *http.Request, containerName string, comp string) {
+	blobs, exists := fs.containers[containerName]
+	switch {
+	case r.Method == http.MethodPut:
+		if exists {
+			writeFakeError(w, r, http.StatusConflict, "ContainerAlreadyExists")
+			return
+		}
+		fs.containers[containerName] = map[string]*fakeBlob{}
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodDelete:
+		if !exists {
+			writeFakeError(w, r, http.StatusNotFound, "ContainerNotFound")
+			return
+		}
+		delete(fs.containers, containerName)
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodGet && comp == "list":
+		if !exists {
+			writeFakeError(w, r, http.StatusNotFound, "ContainerNotFound")
+			return
+		}
+		fs.serveList(w, r, containerName, blobs)
+
+	default:
+		writeFakeError(w, r, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+type fakeBlock struct {
+	Name string `xml:"Name"`
+	Size int    `xml:"Size"`
+}
+
+type fakeBlockList struct {
+	XMLName           xml.Name    `xml:"BlockList"`
+	CommittedBlocks   []fakeBlock `xml:"CommittedBlocks>Block"`
+	UncommittedBlocks []fakeBlock `xml:"UncommittedBlocks>Block"`
+}
+
+type fakeListBlob struct {
+	Name       string `xml:"Name"`
+	Properties struct {
+		LastModified  string `xml:"Last-Modified"`
+		Etag          string `xml:"Etag"`
+		ContentLength int    `xml:"Content-Length"`
+		BlobType      string `xml:"BlobType"`
+	} `xml:"Properties"`
+}
+
+type fakeListResult struct {
+	XMLName       xml.Name       `xml:"EnumerationResults"`
+	ContainerName string         `xml:"ContainerName,attr"`
+	Prefix        string         `xml:"Prefix"`
+	Blobs         []fakeListBlob `xml:"Blobs>Blob"`
+	NextMarker    string         `xml:"NextMarker"`
+}
+
+func (fs *fakeBlobServer) serveList(w http.ResponseWriter, r *http.Request, containerName string, blobs map[string]*fakeBlob) {
+	var result fakeListResult
+	result.ContainerName = containerName
+	result.Prefix = r.URL.Query().Get("prefix")
+
+	var names []string
+	for name := range blobs {
+		if strings.HasPrefix(name, result.Prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var item fakeListBlob
+		item.Name = name
+		item.Properties.LastModified = blobs[name].lastModified.UTC().Format(http.TimeFormat)
+		item.Properties.Etag = blobs[name].etag
+		item.Properties.ContentLength = len(blobs[name].data)
+		item.Properties.BlobType = "BlockBlob"
+		result.Blobs = append(result.Blobs, item)
+	}
+
+	data, err := xml.Marshal(result)
+	if err != nil {
+		writeFakeError(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
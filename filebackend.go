@@ -0,0 +1,529 @@
+/*
+	The file backend stores the documents of a Container in a local directory tree such as a NAS mount or a plain
+	folder. It is selected with containerProperties.Type "file" and containerProperties.Path. Each document is stored
+	as <Path>/<Container>/<remote-name>, and its ETag and metadata (e.g. pitmd5) are stored alongside it in
+	<Path>/<Container>/.pitmeta/<remote-name>.json. Temporary and lock files start with ".pit-" and are not listed
+	as documents.
+*/
+package pit
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileBackendMetadataFolderName = ".pitmeta"
+const fileBackendReservedPrefix = ".pit-"
+
+// Returns the name of an internal file next to fileName, e.g. "clips/.pit-intro.mp4.tmp" for "clips/intro.mp4".
+func fileBackendReservedName(fileName string, suffix string) string {
+	return filepath.Join(filepath.Dir(fileName), fileBackendReservedPrefix+filepath.Base(fileName)+suffix)
+}
+
+type fileBackend struct {
+	root string // Directory of the Container, i.e. <Path>/<Container>.
+}
+
+// The JSON sidecar stored for every document.
+type fileBackendMetadata struct {
+	ETag     string
+	Metadata map[string]string
+}
+
+func newFileBackend(container containerProperties) (*fileBackend, error) {
+	if len(container.Path) == 0 {
+		return nil, errors.New(fmt.Sprintf("Container \"%s\" of type \"file\" does not have a Path", container.Name))
+	}
+
+	root, err := filepath.Abs(filepath.Join(container.Path, container.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	backend := new(fileBackend)
+	backend.root = root
+	return backend, nil
+}
+
+// A contextReader fails once the context is done, so that copying a large document can be cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+func (fb *fileBackend) documentPath(remoteName string) string {
+	return filepath.Join(fb.root, filepath.FromSlash(remoteName))
+}
+
+func (fb *fileBackend) metadataPath(remoteName string) string {
+	return filepath.Join(fb.root, fileBackendMetadataFolderName, filepath.FromSlash(remoteName)+".json")
+}
+
+// Staged blocks are stored in <Path>/<Container>/.pitmeta/<remote-name>.blocks/<hex-encoded-block-id>.
+func (fb *fileBackend) blocksPath(remoteName string) string {
+	return filepath.Join(fb.root, fileBackendMetadataFolderName, filepath.FromSlash(remoteName)+".blocks")
+}
+
+var fileBackendETagMutex sync.Mutex
+var fileBackendLastETag int64
+
+// Returns a new ETag in the same style as Azure (e.g. "0x8D9A2B3C4D5E6F7").
+func newFileBackendETag() string {
+	fileBackendETagMutex.Lock()
+	defer fileBackendETagMutex.Unlock()
+
+	t := time.Now().UnixNano()
+	if t <= fileBackendLastETag {
+		t = fileBackendLastETag + 1
+	}
+	fileBackendLastETag = t
+	return fmt.Sprintf("0x%X", t)
+}
+
+// Writes data to fileName by way of a temporary file so that readers never see a partial file.
+func writeFileAtomic(fileName string, r io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	tmpFileName := fileBackendReservedName(fileName, ".tmp")
+	out, err := os.Create(tmpFileName)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, r)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		deleteFile(tmpFileName)
+		return err
+	}
+
+	return os.Rename(tmpFileName, fileName)
+}
+
+func (fb *fileBackend) readMetadata(remoteName string) (fileBackendMetadata, error) {
+	var meta fileBackendMetadata
+	data, err := ioutil.ReadFile(fb.metadataPath(remoteName))
+	if os.IsNotExist(err) {
+		return meta, nil
+	} else if err != nil {
+		return meta, err
+	}
+
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+func (fb *fileBackend) writeMetadata(remoteName string, meta fileBackendMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fb.metadataPath(remoteName), strings.NewReader(string(data)))
+}
+
+// How long to wait for another computer's lock on a document, and when to consider a lock abandoned. The holder
+// of a lock touches it every quarter of fileBackendStaleLockAge, so only a lock whose holder died becomes stale,
+// however long a copy of a large document takes.
+const fileBackendLockTimeout = 30 * time.Second
+
+var fileBackendStaleLockAge = 10 * time.Minute
+
+// Locks the document so that access conditions can be checked and applied atomically, also across computers
+// that share the directory. The returned function releases the lock.
+func (fb *fileBackend) lock(remoteName string) (func(), error) {
+	lockFileName := fileBackendReservedName(fb.metadataPath(remoteName), ".lock")
+	err := os.MkdirAll(filepath.Dir(lockFileName), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	staleLockAge := fileBackendStaleLockAge
+	deadline := time.Now().Add(fileBackendLockTimeout)
+	for {
+		lockFile, err := os.OpenFile(lockFileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			lockFile.Close()
+			return keepLockFresh(lockFileName, staleLockAge/4), nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockFileName); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			deleteFile(lockFileName)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New(fmt.Sprintf("Timed out waiting for lock %s", lockFileName))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Touches the lock file every interval until the returned function is called, which deletes the lock file.
+func keepLockFresh(lockFileName string, interval time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				os.Chtimes(lockFileName, now, now)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		deleteFile(lockFileName)
+	}
+}
+
+// Returns the ETag of the document and whether it exists.
+func (fb *fileBackend) currentETag(remoteName string) (string, bool, error) {
+	info, err := os.Stat(fb.documentPath(remoteName))
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	meta, err := fb.readMetadata(remoteName)
+	if err != nil {
+		return "", true, err
+	}
+
+	// Documents copied into the directory by hand do not have a sidecar so derive the ETag from the file.
+	if len(meta.ETag) == 0 {
+		meta.ETag = fmt.Sprintf("0x%X", info.ModTime().UnixNano())
+	}
+	return meta.ETag, true, nil
+}
+
+// Must be called while the document is locked.
+func (fb *fileBackend) checkConditions(remoteName string, conditions accessConditions) error {
+	etag, exists, err := fb.currentETag(remoteName)
+	if err != nil {
+		return err
+	}
+
+	if conditions.IfNoneMatch == etagAny && exists {
+		return &ConflictError{RemoteName: remoteName, Err: errors.New("document already exists")}
+	}
+	if len(conditions.IfMatch) != 0 && (!exists || etag != conditions.IfMatch) {
+		return &ConflictError{RemoteName: remoteName, Err: errors.New("ETag does not match")}
+	}
+	return nil
+}
+
+func (fb *fileBackend) Put(ctx context.Context, localName string, remoteName string, conditions accessConditions) (string, error) {
+	file, err := os.Open(localName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	unlock, err := fb.lock(remoteName)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	err = fb.checkConditions(remoteName, conditions)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeFileAtomic(fb.documentPath(remoteName), contextReader{ctx, file})
+	if err != nil {
+		return "", err
+	}
+
+	// As with Azure, uploading a document replaces its metadata and discards staged blocks.
+	os.RemoveAll(fb.blocksPath(remoteName))
+	var meta fileBackendMetadata
+	meta.ETag = newFileBackendETag()
+	meta.Metadata = map[string]string{}
+	return meta.ETag, fb.writeMetadata(remoteName, meta)
+}
+
+func (fb *fileBackend) StageBlock(ctx context.Context, remoteName string, blockID string, data []byte) error {
+	blockFileName := filepath.Join(fb.blocksPath(remoteName), hex.EncodeToString([]byte(blockID)))
+	return writeFileAtomic(blockFileName, bytes.NewReader(data))
+}
+
+func (fb *fileBackend) StagedBlocks(ctx context.Context, remoteName string) ([]string, error) {
+	infos, err := ioutil.ReadDir(fb.blocksPath(remoteName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var blockIDs []string
+	for _, info := range infos {
+		blockID, err := hex.DecodeString(info.Name())
+		if err == nil {
+			blockIDs = append(blockIDs, string(blockID))
+		}
+	}
+	return blockIDs, nil
+}
+
+func (fb *fileBackend) CommitBlocks(ctx context.Context, remoteName string, blockIDs []string, conditions accessConditions) (string, error) {
+	unlock, err := fb.lock(remoteName)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	err = fb.checkConditions(remoteName, conditions)
+	if err != nil {
+		return "", err
+	}
+
+	var blocks []io.Reader
+	closeBlocks := func() {
+		for _, block := range blocks {
+			block.(*os.File).Close()
+		}
+	}
+	for _, blockID := range blockIDs {
+		block, err := os.Open(filepath.Join(fb.blocksPath(remoteName), hex.EncodeToString([]byte(blockID))))
+		if err != nil {
+			closeBlocks()
+			return "", errors.New(fmt.Sprintf("Block %s of %s has not been staged", blockID, remoteName))
+		}
+		blocks = append(blocks, block)
+	}
+
+	err = writeFileAtomic(fb.documentPath(remoteName), contextReader{ctx, io.MultiReader(blocks...)})
+	closeBlocks()
+	if err != nil {
+		return "", err
+	}
+	os.RemoveAll(fb.blocksPath(remoteName))
+
+	var meta fileBackendMetadata
+	meta.ETag = newFileBackendETag()
+	meta.Metadata = map[string]string{}
+	return meta.ETag, fb.writeMetadata(remoteName, meta)
+}
+
+func (fb *fileBackend) Get(ctx context.Context, remoteName string, offset int64, w io.Writer) error {
+	file, err := os.Open(fb.documentPath(remoteName))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrNotFound, remoteName)
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, contextReader{ctx, file})
+	return err
+}
+
+func (fb *fileBackend) Stat(ctx context.Context, remoteName string) (remoteDocument, error) {
+	var remoteDoc remoteDocument
+	etag, exists, err := fb.currentETag(remoteName)
+	if err != nil {
+		return remoteDoc, err
+	} else if !exists {
+		return remoteDoc, fmt.Errorf("%w: %s", ErrNotFound, remoteName)
+	}
+
+	info, err := os.Stat(fb.documentPath(remoteName))
+	if err != nil {
+		return remoteDoc, err
+	}
+
+	meta, err := fb.readMetadata(remoteName)
+	if err != nil {
+		return remoteDoc, err
+	}
+
+	remoteDoc.Name = remoteName
+	remoteDoc.ETag = etag
+	remoteDoc.LastModified = info.ModTime()
+	remoteDoc.Size = info.Size()
+	remoteDoc.Metadata = map[string]string{}
+	for k, v := range meta.Metadata {
+		remoteDoc.Metadata[k] = v
+	}
+	remoteDoc.MD5 = remoteDoc.Metadata[pitMD5tag]
+	return remoteDoc, nil
+}
+
+func (fb *fileBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(fb.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == fb.root {
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == fileBackendMetadataFolderName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(fb.root, path)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(info.Name(), fileBackendReservedPrefix) {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+
+	return names, err
+}
+
+func (fb *fileBackend) Delete(ctx context.Context, remoteName string, conditions accessConditions) error {
+	unlock, err := fb.lock(remoteName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if fileExists(fb.documentPath(remoteName)) {
+		err = fb.checkConditions(remoteName, conditions)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Remove(fb.documentPath(remoteName))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrNotFound, remoteName)
+	} else if err != nil {
+		return err
+	}
+
+	deleteFile(fb.metadataPath(remoteName))
+	return nil
+}
+
+func (fb *fileBackend) Copy(ctx context.Context, sourceRemoteName string, remoteName string, conditions accessConditions) (string, error) {
+	source, err := os.Open(fb.documentPath(sourceRemoteName))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, sourceRemoteName)
+	} else if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	sourceMeta, err := fb.readMetadata(sourceRemoteName)
+	if err != nil {
+		return "", err
+	}
+
+	unlock, err := fb.lock(remoteName)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	err = fb.checkConditions(remoteName, conditions)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeFileAtomic(fb.documentPath(remoteName), contextReader{ctx, source})
+	if err != nil {
+		return "", err
+	}
+
+	var meta fileBackendMetadata
+	meta.ETag = newFileBackendETag()
+	meta.Metadata = map[string]string{}
+	for k, v := range sourceMeta.Metadata {
+		meta.Metadata[k] = v
+	}
+	return meta.ETag, fb.writeMetadata(remoteName, meta)
+}
+
+func (fb *fileBackend) SetMetadata(ctx context.Context, remoteName string, metadata map[string]string, conditions accessConditions) (string, error) {
+	if !fileExists(fb.documentPath(remoteName)) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, remoteName)
+	}
+
+	unlock, err := fb.lock(remoteName)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	err = fb.checkConditions(remoteName, conditions)
+	if err != nil {
+		return "", err
+	}
+
+	meta, err := fb.readMetadata(remoteName)
+	if err != nil {
+		return "", err
+	}
+
+	if meta.Metadata == nil {
+		meta.Metadata = map[string]string{}
+	}
+	for k, v := range metadata {
+		meta.Metadata[k] = v
+	}
+
+	// As with Azure, setting metadata changes the ETag.
+	meta.ETag = newFileBackendETag()
+	return meta.ETag, fb.writeMetadata(remoteName, meta)
+}
+
+func (fb *fileBackend) URL(remoteName string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(fb.documentPath(remoteName))}
+	return u.String()
+}
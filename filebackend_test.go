@@ -0,0 +1,149 @@
+package pit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestFileBackend(t *testing.T) *fileBackend {
+	backend, err := newFileBackend(containerProperties{Type: "file", Path: t.TempDir(), Name: "videos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestFileBackendListSkipsOnlyInternalFiles(t *testing.T) {
+	backend := newTestFileBackend(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"clips/notes.tmp", "clips/intro.mp4", "clips/session.lock"} {
+		if _, err := backend.Put(ctx, tempTestFile(t, name), name, accessConditions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Left behind by an interrupted upload.
+	writeTestFile(t, filepath.Join(backend.root, "clips", ".pit-outro.mp4.tmp"), "partial")
+
+	names, err := backend.List(ctx, "clips/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"clips/intro.mp4", "clips/notes.tmp", "clips/session.lock"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("List returned %v, expected %v", names, expected)
+	}
+}
+
+func TestFileBackendPutLeavesNoTemporaryFile(t *testing.T) {
+	backend := newTestFileBackend(t)
+
+	if _, err := backend.Put(context.Background(), tempTestFile(t, "content"), "clips/intro.mp4", accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+	infos, err := os.ReadDir(filepath.Join(backend.root, "clips"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != "intro.mp4" {
+		t.Errorf("expected only intro.mp4 in the directory, found %v", infos)
+	}
+	if _, err := os.Stat(fileBackendReservedName(backend.metadataPath("clips/intro.mp4"), ".lock")); !os.IsNotExist(err) {
+		t.Errorf("the lock was not released: %v", err)
+	}
+}
+
+func TestFileBackendLockStaysFreshWhileHeld(t *testing.T) {
+	defer func(age time.Duration) { fileBackendStaleLockAge = age }(fileBackendStaleLockAge)
+	fileBackendStaleLockAge = 200 * time.Millisecond
+	backend := newTestFileBackend(t)
+	lockFileName := fileBackendReservedName(backend.metadataPath("intro.mp4"), ".lock")
+
+	unlock, err := backend.lock("intro.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Held for longer than the stale age, as during a long copy.
+	time.Sleep(500 * time.Millisecond)
+	info, err := os.Stat(lockFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if age := time.Since(info.ModTime()); age > fileBackendStaleLockAge {
+		t.Errorf("the lock of a live holder is %v old and would be taken over", age)
+	}
+
+	unlock()
+	if _, err := os.Stat(lockFileName); !os.IsNotExist(err) {
+		t.Errorf("the lock was not released: %v", err)
+	}
+}
+
+func TestFileBackendTakesOverAbandonedLock(t *testing.T) {
+	backend := newTestFileBackend(t)
+	lockFileName := fileBackendReservedName(backend.metadataPath("intro.mp4"), ".lock")
+
+	writeTestFile(t, lockFileName, "")
+	abandoned := time.Now().Add(-2 * fileBackendStaleLockAge)
+	if err := os.Chtimes(lockFileName, abandoned, abandoned); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := backend.lock("intro.mp4")
+	if err != nil {
+		t.Fatalf("an abandoned lock was not taken over: %v", err)
+	}
+	unlock()
+}
+
+func TestFileBackendGetStatDelete(t *testing.T) {
+	backend := newTestFileBackend(t)
+	ctx := context.Background()
+
+	etag, err := backend.Put(ctx, tempTestFile(t, "0123456789"), "clips/intro.mp4", accessConditions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag, err = backend.SetMetadata(ctx, "clips/intro.mp4", map[string]string{pitMD5tag: "md5"}, accessConditions{IfMatch: etag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteDoc, err := backend.Stat(ctx, "clips/intro.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remoteDoc.ETag != etag || remoteDoc.Size != 10 || remoteDoc.MD5 != "md5" || remoteDoc.LastModified.IsZero() {
+		t.Errorf("unexpected properties %+v", remoteDoc)
+	}
+
+	var buffer bytes.Buffer
+	if err := backend.Get(ctx, "clips/intro.mp4", 4, &buffer); err != nil || buffer.String() != "456789" {
+		t.Errorf("Get from offset 4 returned \"%s\", %v", buffer.String(), err)
+	}
+	if err := backend.Get(ctx, "clips/missing.mp4", 0, &buffer); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing document, got %v", err)
+	}
+
+	if err := backend.Delete(ctx, "clips/intro.mp4", accessConditions{IfMatch: "stale"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a conflict when deleting with a stale ETag, got %v", err)
+	}
+	if err := backend.Delete(ctx, "clips/intro.mp4", accessConditions{IfMatch: etag}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Stat(ctx, "clips/intro.mp4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted document, got %v", err)
+	}
+	if _, err := os.Stat(backend.metadataPath("clips/intro.mp4")); !os.IsNotExist(err) {
+		t.Errorf("the metadata of a deleted document was kept: %v", err)
+	}
+
+	if url := backend.URL("clips/intro.mp4"); url != "file://"+filepath.ToSlash(filepath.Join(backend.root, "clips", "intro.mp4")) {
+		t.Errorf("unexpected URL %s", url)
+	}
+}
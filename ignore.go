@@ -0,0 +1,246 @@
+/*
+	Documents are identified by their path relative to the collection root, with forward slashes (e.g.
+	"clips/intro.mp4"), so documents with the same file name in different folders do not collide. Files that
+	should not be added by "pit add <folder>" or "pit add --all" are listed in a .pitignore file in the collection
+	root, which uses the same patterns as .gitignore:
+
+		# Comments and blank lines are ignored.
+		*.psd          Ignore files (or folders) with this name in any folder.
+		drafts/        A trailing "/" only matches folders.
+		/notes.txt     A leading "/", or a "/" in the middle, matches relative to the collection root.
+		raw/**         "**" matches any number of folders, e.g. all files in the raw folder.
+		!keep.psd      A leading "!" adds back files ignored by an earlier pattern.
+*/
+package pit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Files that pit creates or uses itself, and files created by the operating system, are never documents.
+var defaultIgnorePatterns = []string{pitFileName, pitUploadsFileName, pitIgnoreFileName, "*.tmp", ".DS_Store"}
+
+type ignorePattern struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool // Matched against the whole path instead of any file or folder name.
+}
+
+type ignoreList []ignorePattern
+
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	var pattern ignorePattern
+	line = strings.TrimRight(line, " \t\r")
+	if len(line) == 0 || strings.HasPrefix(line, "#") {
+		return pattern, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\") {
+		// Escapes a leading "#" or "!".
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		pattern.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	pattern.pattern = line
+	return pattern, len(line) != 0
+}
+
+// Returns the patterns of the .pitignore file in the collection root, after the default patterns.
+func readIgnoreList(op *operation) (ignoreList, error) {
+	var list ignoreList
+	for _, line := range defaultIgnorePatterns {
+		pattern, _ := parseIgnorePattern(line)
+		list = append(list, pattern)
+	}
+
+	file, err := os.Open(op.path(pitIgnoreFileName))
+	if os.IsNotExist(err) {
+		return list, nil
+	} else if err != nil {
+		return list, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if pattern, ok := parseIgnorePattern(scanner.Text()); ok {
+			list = append(list, pattern)
+		}
+	}
+	return list, scanner.Err()
+}
+
+// Returns true if the document name (e.g. "clips/intro.mp4") matches a glob pattern in which "**" matches any
+// number of folders.
+func matchGlob(pattern string, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(patterns []string, names []string) bool {
+	if len(patterns) == 0 {
+		return len(names) == 0
+	}
+
+	if patterns[0] == "**" {
+		for i := 0; i <= len(names); i++ {
+			if matchGlobSegments(patterns[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(names) == 0 {
+		return false
+	}
+	matched, err := path.Match(patterns[0], names[0])
+	return err == nil && matched && matchGlobSegments(patterns[1:], names[1:])
+}
+
+func (pattern ignorePattern) matches(name string, isDir bool) bool {
+	if pattern.dirOnly && !isDir {
+		return false
+	}
+	if pattern.anchored {
+		return matchGlob(pattern.pattern, name)
+	}
+	return matchGlob(pattern.pattern, path.Base(name))
+}
+
+// Returns true if the file or folder is ignored, either by itself or because a folder it is in is ignored.
+func (list ignoreList) ignored(name string, isDir bool) bool {
+	if dir := path.Dir(name); dir != "." && list.ignored(dir, true) {
+		return true
+	}
+
+	// As with .gitignore, the last matching pattern decides.
+	ignored := false
+	for _, pattern := range list {
+		if pattern.matches(name, isDir) {
+			ignored = !pattern.negate
+		}
+	}
+	return ignored
+}
+
+// Returns the name of the document at filePathAndName, i.e. its path relative to the collection root with forward
+// slashes.
+func documentName(op *operation, filePathAndName string) (string, error) {
+	root, err := filepath.Abs(op.dir)
+	if err != nil {
+		return "", err
+	}
+	absolutePath, err := filepath.Abs(op.path(filePathAndName))
+	if err != nil {
+		return "", err
+	}
+
+	relativePath, err := filepath.Rel(root, absolutePath)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(os.PathSeparator)) {
+		return "", errors.New(fmt.Sprintf("\"%s\" is outside the Collection", filePathAndName))
+	}
+	return filepath.ToSlash(relativePath), nil
+}
+
+// Returns an error unless the document name is a path relative to the collection root that stays inside it. The
+// names in a remote collection are checked before any file is written with them.
+func checkDocumentName(name string) error {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if len(name) == 0 || path.IsAbs(slashed) || filepath.IsAbs(name) || len(filepath.VolumeName(name)) != 0 {
+		return errors.New(fmt.Sprintf("Invalid document name \"%s\"", name))
+	}
+	for _, segment := range strings.Split(slashed, "/") {
+		if len(segment) == 0 || segment == "." || segment == ".." {
+			return errors.New(fmt.Sprintf("Invalid document name \"%s\"", name))
+		}
+	}
+	return nil
+}
+
+// Calls fn with the name of each file in the folder, and its sub-folders, that is not ignored.
+func walkDocuments(op *operation, dir string, list ignoreList, fn func(name string) error) error {
+	return filepath.Walk(op.path(dir), func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := documentName(op, filePath)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+
+		if list.ignored(name, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return fn(name)
+	})
+}
+
+// Returns the names of the documents selected by the folders, files, and glob patterns, e.g. "clips" or "*.mp4".
+func expandDocumentPaths(op *operation, paths []string, list ignoreList) ([]string, error) {
+	var names []string
+	for _, filePath := range paths {
+		matches := []string{filePath}
+		if strings.ContainsAny(filePath, "*?[") {
+			matches, _ = filepath.Glob(op.path(filePath))
+			if len(matches) == 0 {
+				return names, errors.New(fmt.Sprintf("\"%s\" did not match any files", filePath))
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(op.path(match))
+			if err != nil {
+				return names, errors.New(fmt.Sprintf("File \"%s\" does not exist", match))
+			}
+
+			if info.IsDir() {
+				err = walkDocuments(op, match, list, func(name string) error {
+					names = append(names, name)
+					return nil
+				})
+				if err != nil {
+					return names, err
+				}
+				continue
+			}
+
+			name, err := documentName(op, match)
+			if err != nil {
+				return names, err
+			}
+			if list.ignored(name, false) {
+				fmt.Fprintf(op.out, "%s ignored by %s\n", padRight(name, " ", 20), pitIgnoreFileName)
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,143 @@
+package pit
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreList(t *testing.T) {
+	var list ignoreList
+	for _, line := range []string{"# comment", "", "*.psd", "!keep.psd", "drafts/", "/notes.txt", "raw/**/*.wav", "\\#hash"} {
+		if pattern, ok := parseIgnorePattern(line); ok {
+			list = append(list, pattern)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		isDir   bool
+		ignored bool
+	}{
+		{"cover.psd", false, true},
+		{"art/cover.psd", false, true},
+		{"art/keep.psd", false, false},
+		{"drafts", true, true},
+		{"drafts", false, false},
+		{"clips/drafts/intro.mp4", false, true},
+		{"notes.txt", false, true},
+		{"clips/notes.txt", false, false},
+		{"raw/take.wav", false, true},
+		{"raw/day1/take.wav", false, true},
+		{"raw/take.mp4", false, false},
+		{"#hash", false, true},
+		{"intro.mp4", false, false},
+	}
+	for _, test := range tests {
+		if ignored := list.ignored(test.name, test.isDir); ignored != test.ignored {
+			t.Errorf("ignored(%q, %v) = %v", test.name, test.isDir, ignored)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"*.mp4", "intro.mp4", true},
+		{"*.mp4", "clips/intro.mp4", false},
+		{"clips/*", "clips/intro.mp4", true},
+		{"**/*.mp4", "intro.mp4", true},
+		{"**/*.mp4", "clips/day1/intro.mp4", true},
+		{"clips/**", "clips/day1/intro.mp4", true},
+	}
+	for _, test := range tests {
+		if matched := matchGlob(test.pattern, test.name); matched != test.matched {
+			t.Errorf("matchGlob(%q, %q) = %v", test.pattern, test.name, matched)
+		}
+	}
+}
+
+func TestAddFoldersAndGlobs(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	for _, name := range []string{"clips/a.mp4", "b/a.mp4", "intro.mp4", "cover.psd", "drafts/cut.mp4", "b/c/d.mp4"} {
+		writeTestFile(t, name, "content of "+name)
+	}
+	writeTestFile(t, "intro.mp4.tmp", "partial download")
+	writeTestFile(t, pitIgnoreFileName, "*.psd\ndrafts/\n")
+
+	captureOutput(t, func() {
+		if err := collectionAddPaths(te.op, []string{"clips", "*.mp4"}, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertDocumentNames(t, "clips/a.mp4 intro.mp4")
+
+	output := captureOutput(t, func() { collectionAddPaths(te.op, []string{"cover.psd"}, false) })
+	assertContains(t, output, "cover.psd            ignored by .pitignore")
+
+	captureOutput(t, func() { collectionAddPaths(te.op, nil, true) })
+	assertDocumentNames(t, "b/a.mp4 b/c/d.mp4 clips/a.mp4 intro.mp4")
+
+	if err := collectionAddPaths(te.op, []string{"*.mov"}, false); err == nil {
+		t.Errorf("a pattern that does not match any files did not fail")
+	}
+
+	// Documents with the same file name in different folders are pushed and cloned separately.
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	te.chdirComputer("computer2")
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{}) })
+	te.chdirCloned()
+	for _, name := range []string{"clips/a.mp4", "b/a.mp4", "b/c/d.mp4"} {
+		if readTestFile(t, name) != "content of "+name {
+			t.Errorf("%s was not cloned", name)
+		}
+	}
+}
+
+func assertDocumentNames(t *testing.T, expected string) {
+	t.Helper()
+	var names []string
+	for _, doc := range readTestCollection(t).Documents {
+		names = append(names, doc.NameLocal)
+	}
+	sort.Strings(names)
+	if strings.Join(names, " ") != expected {
+		t.Errorf("expected documents %s, got %v", expected, names)
+	}
+}
+
+func TestStatusReportsUntrackedMissingAndModified(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	for _, name := range []string{"intro.mp4", "outro.mp4", "clips/a.mp4"} {
+		writeTestFile(t, name, "content of "+name)
+		collectionAdd(te.op, name)
+	}
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	writeTestFile(t, "intro.mp4", "new content of intro.mp4")
+	deleteFile("outro.mp4")
+	writeTestFile(t, "clips/b.mp4", "content of clips/b.mp4")
+	writeTestFile(t, "cover.psd", "cover")
+	writeTestFile(t, "clips/b.mp4.tmp", "partial download")
+	writeTestFile(t, pitIgnoreFileName, "*.psd\n")
+
+	output := captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "clips/a.mp4          verified and shared as")
+	assertContains(t, output, "Changes not added with \"pit add\":")
+	assertContains(t, output, "    modified:   intro.mp4\n")
+	assertContains(t, output, "    missing:    outro.mp4\n")
+	assertContains(t, output, "Untracked documents:")
+	assertContains(t, output, "    clips/b.mp4\n")
+	for _, name := range []string{"cover.psd", ".tmp", pitFileName, pitIgnoreFileName, "    clips/a.mp4"} {
+		if strings.Contains(output, name) {
+			t.Errorf("status reported %s:\n%s", name, output)
+		}
+	}
+}
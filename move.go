@@ -0,0 +1,154 @@
+/*
+	"pit mv" renames a document. The remote name of a document is derived from its local name, so the next
+	"pit push" copies the remote document to its new name on the server and deletes the old one. With
+	"pit mv --keep-alias" the old remote document is kept as an alias, which is updated whenever the document is
+	pushed, so links that were already shared keep working.
+*/
+package pit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Returns the index of the document that was renamed from nameLocal but not yet moved remotely, or -1.
+func movedIndex(props collectionProperties, nameLocal string) int {
+	for index, doc := range props.Documents {
+		if doc.MovedFrom == nameLocal {
+			return index
+		}
+	}
+	return -1
+}
+
+func containsName(names []string, name string) bool {
+	for _, element := range names {
+		if element == name {
+			return true
+		}
+	}
+	return false
+}
+
+func collectionMove(op *operation, oldName string, newName string, keepAlias bool) error {
+	props, err := collectionRead(op)
+	if err != nil {
+		return err
+	}
+
+	oldName, err = documentName(op, oldName)
+	if err != nil {
+		return err
+	}
+	newName, err = documentName(op, newName)
+	if err != nil {
+		return err
+	}
+
+	index := documentIndex(props, oldName)
+	if index < 0 {
+		return errors.New(fmt.Sprintf("\"%s\" is not in the Collection", oldName))
+	}
+	if documentIndex(props, newName) >= 0 || removedIndex(props, newName) >= 0 || fileExists(op.path(newName)) {
+		return errors.New(fmt.Sprintf("\"%s\" already exists", newName))
+	}
+
+	doc := &props.Documents[index]
+	if fileExists(op.path(oldName)) {
+		err = os.MkdirAll(filepath.Dir(op.path(newName)), os.ModePerm)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(op.path(oldName), op.path(newName))
+		if err != nil {
+			return err
+		}
+	} else if !doc.Absent {
+		return errors.New(fmt.Sprintf("\"%s\" does not exist", oldName))
+	}
+
+	// Discard an interrupted upload under the old name.
+	err = uploadStateUpdate(op, getRemoteFileName(props, oldName), nil)
+	if err != nil {
+		return err
+	}
+
+	// Documents that have not been pushed only need to be renamed locally. The remote document of a document that
+	// is renamed again is moved from where it was last pushed.
+	doc.NameLocal = newName
+	if len(doc.ETag) != 0 && len(doc.MovedFrom) == 0 {
+		doc.MovedFrom = oldName
+	}
+	if doc.MovedFrom == newName {
+		doc.MovedFrom = ""
+	}
+	if keepAlias && len(doc.ETag) != 0 && !containsName(doc.Aliases, oldName) {
+		doc.Aliases = append(doc.Aliases, oldName)
+	}
+
+	fmt.Fprintf(op.out, "%s renamed to %s\n", padRight(oldName, " ", 20), newName)
+	return collectionWrite(op, props)
+}
+
+// Copies the remote document from the name it was last pushed as to its new name, and deletes the old remote
+// document unless it is kept as an alias.
+func moveRemoteDocument(ctx context.Context, backend Backend, props collectionProperties, doc *documentProperties, options PushOptions) error {
+	oldRemoteFileName := getRemoteFileName(props, doc.MovedFrom)
+	remoteFileName := getRemoteFileName(props, doc.NameLocal)
+
+	remoteDoc, err := backend.Stat(ctx, oldRemoteFileName)
+	if errors.Is(err, ErrNotFound) {
+		// Nothing to move, e.g. it was removed by another computer, so the document is uploaded as a new document.
+		doc.MovedFrom = ""
+		doc.ETag = ""
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if remoteDoc.ETag != doc.ETag && options.Resolution != ConflictForce {
+		return &ConflictError{RemoteName: oldRemoteFileName, Err: errors.New("ETag does not match")}
+	}
+
+	etag, err := backend.Copy(ctx, oldRemoteFileName, remoteFileName, accessConditions{IfNoneMatch: etagAny})
+	if errors.Is(err, ErrConflict) {
+		// The copy exists already, e.g. a previous push was interrupted before deleting the old document, so the
+		// move only continues if it has the same content.
+		existing, statErr := backend.Stat(ctx, remoteFileName)
+		if statErr != nil {
+			return statErr
+		} else if len(remoteDoc.MD5) == 0 || existing.MD5 != remoteDoc.MD5 {
+			return &ConflictError{RemoteName: remoteFileName,
+				Err: errors.New(fmt.Sprintf("not moved because %s already exists", backend.URL(remoteFileName)))}
+		}
+		etag = existing.ETag
+	} else if err != nil {
+		return err
+	}
+
+	if !containsName(doc.Aliases, doc.MovedFrom) {
+		err = backend.Delete(ctx, oldRemoteFileName, accessConditions{IfMatch: remoteDoc.ETag})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+
+	doc.MovedFrom = ""
+	doc.ETag = etag
+	return nil
+}
+
+// Updates the aliases of the document with the version that was just pushed.
+func updateDocumentAliases(ctx context.Context, backend Backend, props collectionProperties, doc documentProperties) error {
+	remoteFileName := getRemoteFileName(props, doc.NameLocal)
+	for _, alias := range doc.Aliases {
+		_, err := backend.Copy(ctx, remoteFileName, getRemoteFileName(props, alias), accessConditions{})
+		if err != nil {
+			return errors.New(fmt.Sprintf("unable to update %s\n%s", backend.URL(getRemoteFileName(props, alias)), err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package pit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMoveKeepsHistoryAndMovesRemoteDocument(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupRemove(t, te)
+	writeTestFile(t, "intro.mp4", "new content of intro.mp4")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	oldRemoteName := getRemoteFileName(props, "intro.mp4")
+	newRemoteName := getRemoteFileName(props, "opening.mp4")
+
+	captureOutput(t, func() {
+		if err := collectionMove(te.op, "intro.mp4", "opening.mp4", false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if fileExists("intro.mp4") || readTestFile(t, "opening.mp4") != "new content of intro.mp4" {
+		t.Fatalf("intro.mp4 was not renamed")
+	}
+	doc := readTestCollection(t).Documents[documentIndex(readTestCollection(t), "opening.mp4")]
+	if doc.MovedFrom != "intro.mp4" || len(doc.PreviousMD5s) != 1 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+	assertContains(t, captureOutput(t, func() { collectionStatus(te.op) }), "opening.mp4          renamed from intro.mp4")
+
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	assertContains(t, output, "opening.mp4          moved from")
+	if strings.Contains(output, "Uploading") {
+		t.Errorf("the moved document was uploaded again:\n%s", output)
+	}
+	if te.server.blob(testContainerName, oldRemoteName) != nil {
+		t.Errorf("the old remote document was not deleted")
+	}
+	if blob := te.server.blob(testContainerName, newRemoteName); blob == nil || string(blob.data) != "new content of intro.mp4" {
+		t.Errorf("the remote document was not moved: %+v", blob)
+	}
+
+	doc = readTestCollection(t).Documents[documentIndex(readTestCollection(t), "opening.mp4")]
+	if len(doc.MovedFrom) != 0 || len(doc.PreviousMD5s) != 1 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+	assertContains(t, captureOutput(t, func() { collectionStatus(te.op) }), "opening.mp4          verified and shared as")
+}
+
+func TestMoveKeepAlias(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupRemove(t, te)
+
+	captureOutput(t, func() { collectionMove(te.op, "intro.mp4", "opening.mp4", true) })
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	alias := te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4"))
+	if alias == nil || string(alias.data) != "content of intro.mp4" {
+		t.Fatalf("the old URL was not kept: %+v", alias)
+	}
+
+	// The alias is updated when the document is pushed.
+	writeTestFile(t, "opening.mp4", "new content of opening.mp4")
+	collectionAdd(te.op, "opening.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	alias = te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4"))
+	if alias == nil || string(alias.data) != "new content of opening.mp4" {
+		t.Errorf("the alias was not updated: %+v", alias)
+	}
+
+	// Removing the document also deletes the alias.
+	captureOutput(t, func() { collectionRemove(te.op, "opening.mp4", false, false) })
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	if len(te.server.blobNames(testContainerName)) != 2 {
+		t.Errorf("unexpected remote documents %v", te.server.blobNames(testContainerName))
+	}
+}
+
+func TestMoveBeforePush(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "intro")
+	collectionAdd(te.op, "intro.mp4")
+
+	captureOutput(t, func() { collectionMove(te.op, "intro.mp4", "opening.mp4", true) })
+	props := readTestCollection(t)
+	if doc := props.Documents[0]; doc.NameLocal != "opening.mp4" || len(doc.MovedFrom) != 0 || len(doc.Aliases) != 0 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+
+	if err := collectionMove(te.op, "missing.mp4", "other.mp4", false); err == nil {
+		t.Errorf("moving a document that is not in the collection did not fail")
+	}
+	writeTestFile(t, "other.mp4", "other")
+	if err := collectionMove(te.op, "opening.mp4", "other.mp4", false); err == nil {
+		t.Errorf("moving onto an existing file did not fail")
+	}
+}
+
+func TestPullAfterMove(t *testing.T) {
+	te := newTestEnvironment(t)
+	setupRemove(t, te)
+
+	captureOutput(t, func() { collectionMove(te.op, "intro.mp4", "opening.mp4", false) })
+	output := captureOutput(t, func() { collectionPull(te.op, false) })
+	assertContains(t, output, "intro.mp4            renamed to opening.mp4")
+	if fileExists("intro.mp4") {
+		t.Errorf("the old name was downloaded again")
+	}
+}
+
+func TestMoveAfterInterruptedPush(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupRemove(t, te)
+	backend := te.backend(t)
+	oldRemoteName := getRemoteFileName(props, "intro.mp4")
+	newRemoteName := getRemoteFileName(props, "opening.mp4")
+
+	// A push that was interrupted after copying the document but before deleting the old document.
+	captureOutput(t, func() { collectionMove(te.op, "intro.mp4", "opening.mp4", false) })
+	if _, err := backend.Copy(te.op.ctx, oldRemoteName, newRemoteName, accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+	output := captureOutput(t, func() {
+		if err := collectionPush(te.op, PushOptions{}); err != nil {
+			t.Error(err)
+		}
+	})
+	assertContains(t, output, "opening.mp4          moved from")
+	if te.server.blob(testContainerName, oldRemoteName) != nil {
+		t.Errorf("the old remote document was not deleted")
+	}
+
+	// A different document at the new name is a conflict.
+	captureOutput(t, func() { collectionMove(te.op, "opening.mp4", "closing.mp4", false) })
+	if _, err := backend.Copy(te.op.ctx, getRemoteFileName(props, "outro.mp4"), getRemoteFileName(props, "closing.mp4"), accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+	props = readTestCollection(t)
+	doc := props.Documents[documentIndex(props, "closing.mp4")]
+	if err := moveRemoteDocument(te.op.ctx, backend, props, &doc, PushOptions{}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a conflict for a different document at the new name, got %v", err)
+	}
+	if te.server.blob(testContainerName, newRemoteName) == nil {
+		t.Errorf("the remote document was deleted despite the conflict")
+	}
+}
@@ -0,0 +1,223 @@
+/*
+	Profiles are named Containers in account.json, e.g. "test" and "production". Each profile has its own backend
+	type, endpoint, credentials, and Container. The profile used by push, status, and clone is selected with
+	"pit --profile <name>", the PIT_PROFILE environment variable, or "pit profile use <name>", which marks the
+	Container as the default. Containers that were added before profiles existed are named after the Container.
+*/
+package pit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A Profile is a named Container in account.json.
+type Profile struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`               // Values:  "azure", "file", or "s3"
+	Account    string `json:"account,omitempty"`  // The storage account name, or the access key ID for "s3".
+	Key        string `json:"-"`                  // The storage account key, or the secret access key for "s3", stored in the credential store.
+	Container  string `json:"container"`          // The Container, or bucket, name.
+	URL        string `json:"url,omitempty"`      // The URL of the Container, set by AddProfile.
+	Endpoint   string `json:"endpoint,omitempty"` // Example: "http://127.0.0.1:10000/devstoreaccount1" (only used by "azure" and "s3")
+	Region     string `json:"region,omitempty"`   // Example: "us-west-2" (only used by "s3")
+	Path       string `json:"path,omitempty"`     // Example: "/Volumes/nas/pit" (only used by "file")
+	Production bool   `json:"production"`         // A warning is printed whenever a production profile is used.
+	Active     bool   `json:"active"`             // True for the profile that is used when no profile is selected.
+}
+
+// Returns the name of the profile of the Container.
+func (cp containerProperties) profileName() string {
+	if len(cp.Profile) != 0 {
+		return cp.Profile
+	}
+	return cp.Name
+}
+
+func (cp containerProperties) profile() Profile {
+	return Profile{Name: cp.profileName(), Type: cp.Type, Account: cp.Account, Key: cp.Key, Container: cp.Name,
+		URL: cp.URL, Endpoint: cp.Endpoint, Region: cp.Region, Path: cp.Path, Production: cp.Production == "yes",
+		Active: cp.Default == "yes"}
+}
+
+// Returns the profile, i.e. the profile selected with "--profile", otherwise the name of the profile selected with
+// PIT_PROFILE, or an empty string.
+func profileSelection(profile string) string {
+	if len(profile) != 0 {
+		return profile
+	}
+	return os.Getenv("PIT_PROFILE")
+}
+
+// Returns the index of the Container with the profile name or -1.
+func (ap *accountProperties) profileIndex(name string) int {
+	for index, container := range ap.Containers {
+		if container.profileName() == name {
+			return index
+		}
+	}
+	return -1
+}
+
+// Returns the name of the profile of the Container with the URL, or an empty string if the Container is not in
+// account.json (e.g. a collection cloned from a manifest URL).
+func (ap *accountProperties) profileForURL(containerURL string) string {
+	container, found := ap.containerForURL(containerURL)
+	if !found {
+		return ""
+	}
+	return container.profileName()
+}
+
+// Returns the Container in account.json with the URL, or false if the Container is not in account.json.
+func (ap *accountProperties) containerForURL(containerURL string) (containerProperties, bool) {
+	err := ap.read()
+	if err != nil {
+		return containerProperties{}, false
+	}
+
+	for _, container := range ap.Containers {
+		if u, err := container.containerURL(); err == nil && u == strings.TrimSuffix(containerURL, "/") {
+			return container, true
+		}
+	}
+	return containerProperties{}, false
+}
+
+func yesOrNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// Profiles returns the profiles in account.json.
+func (client *Client) Profiles() ([]Profile, error) {
+	account := new(accountProperties)
+	err := account.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []Profile
+	for _, container := range account.Containers {
+		profiles = append(profiles, container.profile())
+	}
+	return profiles, nil
+}
+
+// ActiveProfile returns the profile that operations of the client use.
+func (client *Client) ActiveProfile() (Profile, error) {
+	account := new(accountProperties)
+	container, err := account.defaultContainer(client.Profile)
+	return container.profile(), err
+}
+
+// AddProfile adds the profile to account.json, and its key to the credential store. The first profile becomes the
+// active profile.
+func (client *Client) AddProfile(profile Profile) error {
+	if len(profile.Name) == 0 || strings.ContainsAny(profile.Name, " \t/") {
+		return errors.New(fmt.Sprintf("Invalid profile name \"%s\"", profile.Name))
+	} else if len(profile.Container) == 0 {
+		return errors.New("The profile must include a Container name")
+	}
+
+	account := new(accountProperties)
+	err := account.read()
+	if err != nil {
+		return err
+	}
+	if account.profileIndex(profile.Name) >= 0 {
+		return errors.New(fmt.Sprintf("Profile \"%s\" already exists", profile.Name))
+	}
+
+	container := containerProperties{Profile: profile.Name, Type: profile.Type, Account: profile.Account, Key: profile.Key,
+		Name: profile.Container, Endpoint: profile.Endpoint, Region: profile.Region, Path: profile.Path,
+		Production: yesOrNo(profile.Production), Default: yesOrNo(len(account.Containers) == 0)}
+	if len(container.Type) == 0 {
+		container.Type = "azure"
+	}
+	if container.Type != "azure" && container.Type != "file" && container.Type != "s3" {
+		return errors.New(fmt.Sprintf("Unsupported container type \"%s\"", container.Type))
+	}
+
+	// The URL is unknown if the storage account is only set in the environment.
+	if containerURL, err := container.containerURL(); err == nil {
+		container.URL = containerURL
+	}
+
+	// The key is stored in the credential store instead of in account.json.
+	if len(container.Key) == 0 {
+		account.Containers = append(account.Containers, container)
+		return account.write()
+	}
+	return client.withCredentials(func() error {
+		credentials, err := readCredentials(client.Passphrase)
+		if err != nil {
+			return err
+		}
+		credentials[profile.Name] = container.Key
+		err = writeCredentials(credentials, client.Passphrase)
+		if err != nil {
+			return err
+		}
+
+		container.Key = ""
+		account.Containers = append(account.Containers, container)
+		return account.write()
+	})
+}
+
+// UseProfile makes the profile the active profile.
+func (client *Client) UseProfile(name string) (Profile, error) {
+	account := new(accountProperties)
+	err := account.read()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	index := account.profileIndex(name)
+	if index < 0 {
+		return Profile{}, fmt.Errorf("%w: profile \"%s\"", ErrNotFound, name)
+	}
+	for i := range account.Containers {
+		account.Containers[i].Default = yesOrNo(i == index)
+	}
+	return account.Containers[index].profile(), account.write()
+}
+
+// RemoveProfile removes the profile from account.json, and its key from the credential store.
+func (client *Client) RemoveProfile(name string) error {
+	return client.withCredentials(func() error {
+		account := new(accountProperties)
+		err := account.read()
+		if err != nil {
+			return err
+		}
+
+		index := account.profileIndex(name)
+		if index < 0 {
+			return fmt.Errorf("%w: profile \"%s\"", ErrNotFound, name)
+		}
+
+		// The key is removed first, so that nothing changes if the credential store cannot be unlocked.
+		if fileExists(credentialsFilePath()) {
+			credentials, err := readCredentials(client.Passphrase)
+			if err != nil {
+				return err
+			}
+			if _, ok := credentials[name]; ok {
+				delete(credentials, name)
+				err = writeCredentials(credentials, client.Passphrase)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		account.Containers = append(account.Containers[:index], account.Containers[index+1:]...)
+		return account.write()
+	})
+}
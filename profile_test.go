@@ -0,0 +1,57 @@
+package pit
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	te := newTestEnvironment(t)
+	client := new(Client)
+	nas := filepath.Join(te.root, "nas")
+	if err := client.AddProfile(Profile{Name: "local", Type: "file", Path: nas, Container: "videos"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddProfile(Profile{Name: "production", Type: "file", Path: nas, Container: "prod", Production: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddProfile(Profile{Name: "local", Type: "file", Path: nas, Container: "other"}); err == nil {
+		t.Error("added a profile with the same name twice")
+	}
+
+	// The Container of the test environment was added before profiles and is named after the Container.
+	profiles, err := client.Profiles()
+	if err != nil || len(profiles) != 3 || profiles[0].Name != testContainerName || !profiles[0].Active {
+		t.Fatalf("unexpected profiles %+v, %v", profiles, err)
+	}
+
+	profile, err := client.UseProfile("production")
+	if err != nil || !profile.Production || profile.URL != "file://"+filepath.ToSlash(filepath.Join(nas, "prod")) {
+		t.Fatalf("unexpected profile %+v, %v", profile, err)
+	}
+	if active, _ := client.ActiveProfile(); active.Name != "production" {
+		t.Errorf("expected the production profile to be active, got %s", active.Name)
+	}
+
+	// A profile selected with PIT_PROFILE or Client.Profile is used instead of the active profile.
+	t.Setenv("PIT_PROFILE", "local")
+	if active, _ := client.ActiveProfile(); active.Name != "local" {
+		t.Errorf("expected PIT_PROFILE to select local, got %s", active.Name)
+	}
+	client.Profile = testContainerName
+	if active, _ := client.ActiveProfile(); active.Container != testContainerName {
+		t.Errorf("expected Client.Profile to select %s, got %s", testContainerName, active.Name)
+	}
+
+	client.Profile = "missing"
+	if _, err := client.ActiveProfile(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := client.RemoveProfile("local"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveProfile("local"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
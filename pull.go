@@ -0,0 +1,241 @@
+/*
+	Pull brings documents that were pushed from another computer into the local collection. The remote collection
+	(<NameRemote>.json) is downloaded and every document whose remote MD5 or ETag differs from the local collection is
+	downloaded. Local documents with changes that have not been added, or that have been added but not pushed, are
+	not overwritten unless forced.
+*/
+package pit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Returns the remote Collection with its ETag in ETag. Whether documents are absent is local to each computer, so
+// it is cleared.
+func readRemoteCollection(ctx context.Context, backend Backend, nameRemote string) (collectionProperties, error) {
+	var remoteProps collectionProperties
+	// Stat before downloading so that the ETag is never newer than the content.
+	remoteDoc, err := backend.Stat(ctx, nameRemote+".json")
+	if err != nil {
+		return remoteProps, err
+	}
+
+	var buffer bytes.Buffer
+	err = backend.Get(ctx, nameRemote+".json", 0, &buffer)
+	if err != nil {
+		return remoteProps, err
+	}
+
+	err = json.Unmarshal(buffer.Bytes(), &remoteProps)
+	if err != nil {
+		return remoteProps, err
+	}
+
+	remoteProps.ETag = remoteDoc.ETag
+	for i := range remoteProps.Documents {
+		remoteProps.Documents[i].Absent = false
+
+		// A document name such as "../../.bashrc" would be written outside the collection folder.
+		err = checkDocumentName(remoteProps.Documents[i].NameLocal)
+		if err != nil {
+			return remoteProps, errors.New(fmt.Sprintf("%s in %s", err, backend.URL(nameRemote+".json")))
+		}
+	}
+	return remoteProps, nil
+}
+
+func containsMD5(md5s []string, md5 string) bool {
+	for _, element := range md5s {
+		if element == md5 {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the index of the named document in the collection or -1.
+func documentIndex(props collectionProperties, nameLocal string) int {
+	for index, doc := range props.Documents {
+		if doc.NameLocal == nameLocal {
+			return index
+		}
+	}
+	return -1
+}
+
+// Returns an error if downloading the remote version of the document would lose local changes.
+func verifyPullSafe(op *operation, localDoc *documentProperties, remoteDoc documentProperties, remoteMD5 string) error {
+	if localDoc == nil {
+		// A file that is not in the collection would be overwritten.
+		if fileExists(op.path(remoteDoc.NameLocal)) {
+			localMD5, err := md5File(op.path(remoteDoc.NameLocal))
+			if err != nil {
+				return err
+			}
+			if localMD5 != remoteMD5 {
+				return errors.New("a local file with the same name is not in the collection")
+			}
+		}
+		return nil
+	}
+
+	if fileExists(op.path(localDoc.NameLocal)) {
+		localMD5, err := md5File(op.path(localDoc.NameLocal))
+		if err != nil {
+			return err
+		}
+		if localMD5 != localDoc.MD5 {
+			return errors.New(fmt.Sprintf("local changes have not been added with \"pit add %s\"", localDoc.NameLocal))
+		}
+	}
+
+	// The remote version must be based on the local version, otherwise the local version has not been pushed.
+	if len(localDoc.ETag) == 0 || !containsMD5(remoteDoc.PreviousMD5s, localDoc.MD5) {
+		return errors.New("local version has not been pushed")
+	}
+
+	return nil
+}
+
+// Updates the document after the remote version has been downloaded. The history of both computers is kept so
+// that later pushes recognize the remote versions.
+func acceptRemoteVersion(doc *documentProperties, remoteMD5 string, remoteETag string, remotePreviousMD5s []string) {
+	if len(doc.MD5) != 0 && doc.MD5 != remoteMD5 && !containsMD5(doc.PreviousMD5s, doc.MD5) {
+		doc.PreviousMD5s = append(doc.PreviousMD5s, doc.MD5)
+	}
+	for _, previousMD5 := range remotePreviousMD5s {
+		if previousMD5 != remoteMD5 && !containsMD5(doc.PreviousMD5s, previousMD5) {
+			doc.PreviousMD5s = append(doc.PreviousMD5s, previousMD5)
+		}
+	}
+	doc.MD5 = remoteMD5
+	doc.ETag = remoteETag
+}
+
+func collectionPull(op *operation, force bool) error {
+	props, err := collectionRead(op)
+	if err != nil {
+		return err
+	}
+
+	backend, err := newCollectionBackend(op, props)
+	if err != nil {
+		return err
+	}
+
+	op.reportCollection(props.NameLocal)
+
+	remoteProps, err := readRemoteCollection(op.ctx, backend, props.NameRemote)
+	if errors.Is(err, ErrNotFound) {
+		fmt.Fprintf(op.out, "Collection \"%s\" has not been pushed\n", props.NameLocal)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// The next push replaces the version of the remote Collection that was pulled.
+	collectFileModified := props.ETag != remoteProps.ETag
+	props.ETag = remoteProps.ETag
+
+	for _, remoteDoc := range remoteProps.Documents {
+		remoteFileName, remoteFileURL := getRemoteFileNameAndURL(backend, props, remoteDoc.NameLocal)
+		remoteMD5, remoteETag, err := getRemoteFileMD5AndETag(op.ctx, backend, remoteFileName)
+		report := DocumentReport{Name: remoteDoc.NameLocal, State: StateVerified, RemoteMD5: remoteMD5, ETag: remoteETag,
+			URL: remoteFileURL}
+		if err != nil {
+			report.State = StateError
+			report.Error = err.Error()
+			op.reportDocument(report)
+			fmt.Fprintf(op.out, "%s Error: unable to obtain MD5 or ETag for %s\n", padRight(remoteDoc.NameLocal, " ", 20), remoteFileURL)
+			continue
+		}
+
+		if index := removedIndex(props, remoteDoc.NameLocal); index >= 0 {
+			report.State = StateRemoved
+			op.reportDocument(report)
+			fmt.Fprintf(op.out, "%s removed but not %s\n", padRight(remoteDoc.NameLocal, " ", 20), removedDocumentStatus(props.Removed[index]))
+			continue
+		}
+
+		if index := movedIndex(props, remoteDoc.NameLocal); index >= 0 {
+			report.State = StateRenamed
+			op.reportDocument(report)
+			fmt.Fprintf(op.out, "%s renamed to %s but not moved remotely with \"pit push\"\n", padRight(remoteDoc.NameLocal, " ", 20),
+				props.Documents[index].NameLocal)
+			continue
+		}
+
+		var localDoc *documentProperties
+		if index := documentIndex(props, remoteDoc.NameLocal); index >= 0 {
+			localDoc = &props.Documents[index]
+			report.LocalMD5 = localDoc.MD5
+		}
+
+		if localDoc != nil && localDoc.Absent {
+			// Documents that were not downloaded by a sparse clone are only downloaded with "pit fetch".
+			if localDoc.MD5 != remoteMD5 || localDoc.ETag != remoteETag {
+				acceptRemoteVersion(localDoc, remoteMD5, remoteETag, remoteDoc.PreviousMD5s)
+				collectFileModified = true
+			}
+			report.State = StateRemoteOnly
+			report.LocalMD5 = ""
+			op.reportDocument(report)
+			fmt.Fprintf(op.out, "%s remote only\n", padRight(remoteDoc.NameLocal, " ", 20))
+			continue
+		}
+
+		if localDoc != nil && localDoc.MD5 == remoteMD5 {
+			if localDoc.ETag != remoteETag {
+				// Same content, e.g. pushed from another computer after a pull. Only the ETag needs updating.
+				localDoc.ETag = remoteETag
+				collectFileModified = true
+			}
+			op.reportDocument(report)
+			fmt.Fprintf(op.out, "%s up to date\n", padRight(remoteDoc.NameLocal, " ", 20))
+			continue
+		}
+
+		if !force {
+			err = verifyPullSafe(op, localDoc, remoteDoc, remoteMD5)
+			if err != nil {
+				// The local changes would be lost, so the remote version is in conflict with them.
+				report.State = StateConflict
+				report.Error = err.Error()
+				op.reportDocument(report)
+				fmt.Fprintf(op.out, "%s Error: not updated because %s (use \"pit pull --force\" to overwrite)\n",
+					padRight(remoteDoc.NameLocal, " ", 20), err)
+				continue
+			}
+		}
+
+		err = downloadDocument(op, backend, remoteFileName, remoteDoc.NameLocal, remoteMD5)
+		if err != nil {
+			report.State = StateError
+			report.Error = err.Error()
+			op.reportDocument(report)
+			fmt.Fprintf(op.out, "%s Error: unable to download %s\n%s\n", padRight(remoteDoc.NameLocal, " ", 20), remoteFileURL, err)
+			continue
+		}
+
+		if localDoc == nil {
+			props.Documents = append(props.Documents, documentProperties{NameLocal: remoteDoc.NameLocal})
+			localDoc = &props.Documents[len(props.Documents)-1]
+		}
+
+		acceptRemoteVersion(localDoc, remoteMD5, remoteETag, remoteDoc.PreviousMD5s)
+		collectFileModified = true
+
+		report.LocalMD5 = remoteMD5
+		op.reportDocument(report)
+		fmt.Fprintf(op.out, "%s updated from %s\n", padRight(remoteDoc.NameLocal, " ", 20), remoteFileURL)
+	}
+
+	if collectFileModified {
+		return collectionWrite(op, props)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package pit
+
+import (
+	"strings"
+	"testing"
+)
+
+// Pushes intro.mp4 from computer1, then pushes an update from computer2, and leaves the working directory in
+// computer1's collection.
+func setupRemoteUpdate(t *testing.T, te *testEnvironment) {
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "original")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	te.chdirComputer("computer2")
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{}) })
+	te.chdirCloned()
+	writeTestFile(t, "intro.mp4", "update from computer2")
+	writeTestFile(t, "notes.pdf", "notes from computer2")
+	collectionAdd(te.op, "intro.mp4")
+	collectionAdd(te.op, "notes.pdf")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+
+	te.chdir("computer1", "videos")
+}
+
+func TestPullRemoteUpdate(t *testing.T) {
+	te := newTestEnvironment(t)
+	setupRemoteUpdate(t, te)
+	originalMD5 := md5TestFile(t, "intro.mp4")
+
+	output := captureOutput(t, func() { collectionPull(te.op, false) })
+	assertContains(t, output, "intro.mp4            updated from")
+	assertContains(t, output, "notes.pdf            updated from")
+
+	if readTestFile(t, "intro.mp4") != "update from computer2" {
+		t.Errorf("intro.mp4 was not updated")
+	}
+	if readTestFile(t, "notes.pdf") != "notes from computer2" {
+		t.Errorf("notes.pdf was not downloaded")
+	}
+
+	props := readTestCollection(t)
+	doc := props.Documents[documentIndex(props, "intro.mp4")]
+	blob := te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4"))
+	if doc.MD5 != md5TestFile(t, "intro.mp4") || doc.ETag != strings.Trim(blob.etag, "\"") {
+		t.Errorf("MD5 or ETag not updated: %+v", doc)
+	}
+	if !containsMD5(doc.PreviousMD5s, originalMD5) {
+		t.Errorf("PreviousMD5s does not include the original MD5: %v", doc.PreviousMD5s)
+	}
+
+	output = captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "intro.mp4            verified and shared as")
+	assertContains(t, output, "notes.pdf            verified and shared as")
+
+	// After the pull, an update from this computer is pushed without a version conflict.
+	writeTestFile(t, "intro.mp4", "update from computer1")
+	collectionAdd(te.op, "intro.mp4")
+	output = captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	if blob := te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4")); string(blob.data) != "update from computer1" {
+		t.Errorf("push after pull failed:\n%s", output)
+	}
+}
+
+func TestPullRefusesUnaddedChanges(t *testing.T) {
+	te := newTestEnvironment(t)
+	setupRemoteUpdate(t, te)
+	writeTestFile(t, "intro.mp4", "local edit")
+
+	output := captureOutput(t, func() { collectionPull(te.op, false) })
+	assertContains(t, output, "intro.mp4            Error: not updated because local changes have not been added")
+	if readTestFile(t, "intro.mp4") != "local edit" {
+		t.Errorf("local edit was overwritten")
+	}
+
+	output = captureOutput(t, func() { collectionPull(te.op, true) })
+	assertContains(t, output, "intro.mp4            updated from")
+	if readTestFile(t, "intro.mp4") != "update from computer2" {
+		t.Errorf("intro.mp4 was not updated by a forced pull")
+	}
+}
+
+func TestPullRefusesUnpushedChanges(t *testing.T) {
+	te := newTestEnvironment(t)
+	setupRemoteUpdate(t, te)
+	writeTestFile(t, "intro.mp4", "local edit")
+	collectionAdd(te.op, "intro.mp4")
+
+	output := captureOutput(t, func() { collectionPull(te.op, false) })
+	assertContains(t, output, "intro.mp4            Error: not updated because local version has not been pushed")
+	if readTestFile(t, "intro.mp4") != "local edit" {
+		t.Errorf("local version was overwritten")
+	}
+}
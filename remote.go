@@ -0,0 +1,92 @@
+/*
+	Remote binds a collection to the Container of a profile. A collection is bound to a Container by "pit init",
+	"pit clone", or the first push, and is then always pushed to that Container, whatever profile is active.
+	"pit remote set <profile>" re-points the collection deliberately, after which the next push uploads every
+	document to the new Container.
+*/
+package pit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func collectionSetRemote(op *operation, name string) error {
+	props, err := collectionRead(op)
+	if err != nil {
+		return err
+	}
+
+	account := new(accountProperties)
+	err = account.read()
+	if err != nil {
+		return err
+	}
+	index := account.profileIndex(name)
+	if index < 0 {
+		return fmt.Errorf("%w: profile \"%s\" (see \"pit profile list\")", ErrNotFound, name)
+	}
+	containerURL, err := account.Containers[index].containerURL()
+	if err != nil {
+		return err
+	}
+
+	if containerURL != strings.TrimSuffix(props.URL, "/") {
+		// Documents that were not downloaded by a sparse clone cannot be uploaded to the new Container.
+		for _, doc := range props.Documents {
+			if doc.Absent {
+				return errors.New(fmt.Sprintf("%s has not been downloaded, use \"pit fetch\" before binding the collection to "+
+					"another Container", doc.NameLocal))
+			}
+		}
+
+		// The ETags, renames, and removals are those of the old Container, so the next push uploads every document.
+		props.ETag = ""
+		props.Removed = nil
+		for i := range props.Documents {
+			props.Documents[i].ETag = ""
+			props.Documents[i].MovedFrom = ""
+		}
+	}
+
+	props.URL = containerURL
+	props.Profile = name
+	err = collectionWrite(op, props)
+	if err != nil {
+		return err
+	}
+	err = account.addBasicCollectionInfo(props.NameLocal, props.NameRemote, props.URL)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(op.out, "Collection \"%s\" bound to profile \"%s\" (%s)\n", props.NameLocal, name, props.URL)
+	return nil
+}
+
+func collectionRemote(op *operation) (Remote, error) {
+	var remote Remote
+	props, err := collectionRead(op)
+	if err != nil {
+		return remote, err
+	}
+
+	remote.Name = props.NameLocal
+	remote.Profile = props.Profile
+	remote.URL = props.URL
+	if len(props.URL) == 0 {
+		return remote, nil
+	}
+
+	account := new(accountProperties)
+	if container, found := account.containerForURL(props.URL); found {
+		remote.Type = strings.ToLower(container.Type)
+		if len(remote.Type) == 0 {
+			remote.Type = "azure"
+		}
+	} else if strings.HasPrefix(props.URL, "http://") || strings.HasPrefix(props.URL, "https://") {
+		remote.Type = "url"
+	}
+	return remote, nil
+}
@@ -0,0 +1,78 @@
+package pit
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectionBoundToContainer(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdirComputer("computer1")
+	ctx := context.Background()
+	client := new(Client)
+
+	collection, err := client.Init(ctx, "videos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	te.chdir("computer1", "videos")
+	props := readTestCollection(t)
+	if props.Profile != testContainerName || props.URL != backendContainerURL(te.backend(t)) {
+		t.Fatalf("collection not bound at init, profile %q url %q", props.Profile, props.URL)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(collection.Dir(), "intro.mp4"), []byte("intro"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := collection.Add(ctx, "intro.mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the active profile, or AZURE_CONTAINER_NAME, does not change where the collection is pushed.
+	nas := filepath.Join(te.root, "nas")
+	if err := client.AddProfile(Profile{Name: "nas", Type: "file", Path: nas, Container: "videos"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.UseProfile("nas"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AZURE_CONTAINER_NAME", "other")
+	report, err := collection.Push(ctx, PushOptions{})
+	if err != nil || report.Failed || report.Summary[StateVerified] != 1 {
+		t.Fatalf("unexpected push report %+v, %v", report, err)
+	}
+	if _, err := os.Stat(nas); !os.IsNotExist(err) {
+		t.Error("the collection was pushed to the active profile instead of its Container")
+	}
+
+	// Selecting another profile explicitly is an error until the collection is re-pointed with SetRemote.
+	client.Profile = "nas"
+	if _, err := collection.Status(ctx); err == nil {
+		t.Error("expected an error for a profile the collection is not bound to")
+	}
+	client.Profile = ""
+	if err := collection.SetRemote(ctx, "missing"); err == nil {
+		t.Error("bound the collection to a profile that does not exist")
+	}
+	if err := collection.SetRemote(ctx, "nas"); err != nil {
+		t.Fatal(err)
+	}
+	remote, err := collection.Remote(ctx)
+	if err != nil || remote.Name != "videos" || remote.Profile != "nas" || remote.Type != "file" ||
+		remote.URL != readTestCollection(t).URL {
+		t.Errorf("unexpected remote after SetRemote %+v, %v", remote, err)
+	}
+	report, err = collection.Push(ctx, PushOptions{})
+	if err != nil || report.Failed || report.Summary[StateVerified] != 1 {
+		t.Fatalf("unexpected push report after SetRemote %+v, %v", report, err)
+	}
+	if props := readTestCollection(t); props.Profile != "nas" || len(props.ETag) == 0 {
+		t.Errorf("unexpected collection after SetRemote %+v", props)
+	}
+	if _, err := os.Stat(nas); err != nil {
+		t.Errorf("the collection was not pushed to the new Container: %v", err)
+	}
+}
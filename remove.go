@@ -0,0 +1,154 @@
+/*
+	"pit rm" removes a document from a collection. The remote document stays public until the next "pit push",
+	which deletes it (or first copies it to archive/<remote-name> with "pit rm --archive") and uploads the updated
+	collection. "pit rm --cached" only stops tracking the document and keeps the remote document. The local file
+	is never deleted.
+*/
+package pit
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returns the index of the removed document, or -1 if no document with that name is waiting to be deleted.
+func removedIndex(props collectionProperties, nameLocal string) int {
+	for index, removed := range props.Removed {
+		if removed.NameLocal == nameLocal {
+			return index
+		}
+	}
+	return -1
+}
+
+// Returns the name under which a removed document is archived, e.g. "archive/nvm4zqwm-intro.mp4".
+func getArchiveFileName(props collectionProperties, localFileName string) string {
+	return pitArchivePrefix + getRemoteFileName(props, localFileName)
+}
+
+func collectionRemove(op *operation, nameLocal string, cached bool, archive bool) error {
+	props, err := collectionRead(op)
+	if err != nil {
+		return err
+	}
+
+	nameLocal, err = documentName(op, nameLocal)
+	if err != nil {
+		return err
+	}
+
+	index := documentIndex(props, nameLocal)
+	if index < 0 {
+		return errors.New(fmt.Sprintf("\"%s\" is not in the Collection", nameLocal))
+	}
+	doc := props.Documents[index]
+	props.Documents = append(props.Documents[:index], props.Documents[index+1:]...)
+
+	// Discard an interrupted upload of the document.
+	err = uploadStateUpdate(op, getRemoteFileName(props, nameLocal), nil)
+	if err != nil {
+		return err
+	}
+
+	if cached {
+		fmt.Fprintf(op.out, "%s removed, the remote copy is kept\n", padRight(nameLocal, " ", 20))
+	} else if len(doc.ETag) == 0 {
+		// The document has not been pushed so there is nothing to delete.
+		fmt.Fprintf(op.out, "%s removed\n", padRight(nameLocal, " ", 20))
+	} else {
+		// A document that was renamed but not pushed since is still shared under its old name.
+		nameRemoved := nameLocal
+		if len(doc.MovedFrom) != 0 {
+			nameRemoved = doc.MovedFrom
+		}
+		props.Removed = append(props.Removed, removedDocument{NameLocal: nameRemoved, ETag: doc.ETag, MD5: doc.MD5,
+			Archive: archive, Aliases: doc.Aliases})
+		fmt.Fprintf(op.out, "%s removed, %s\n", padRight(nameLocal, " ", 20), removedDocumentStatus(props.Removed[len(props.Removed)-1]))
+	}
+
+	return collectionWrite(op, props)
+}
+
+func removedDocumentStatus(removed removedDocument) string {
+	if removed.Archive {
+		return "archived and deleted remotely with \"pit push\""
+	}
+	return "deleted remotely with \"pit push\""
+}
+
+// Deletes, or archives, the remote documents of the documents removed with "pit rm". Documents that were changed
+// by another computer since they were removed are only deleted with "pit push --force". Returns true if the
+// collection was modified.
+func pushRemovedDocuments(op *operation, backend Backend, props *collectionProperties, options PushOptions) bool {
+	var remaining []removedDocument
+	for _, removed := range props.Removed {
+		remoteFileName := getRemoteFileName(*props, removed.NameLocal)
+		report := DocumentReport{Name: removed.NameLocal, State: StateRemoved, LocalMD5: removed.MD5, ETag: removed.ETag}
+		remoteDoc, err := backend.Stat(op.ctx, remoteFileName)
+		if errors.Is(err, ErrNotFound) {
+			op.reportDocument(report)
+			op.printDocumentLine(removed.NameLocal, "removed")
+			continue
+		} else if err != nil {
+			report.State = StateError
+			report.Error = err.Error()
+			op.reportDocument(report)
+			op.printDocumentLine(removed.NameLocal, "Error: %s", err)
+			remaining = append(remaining, removed)
+			continue
+		}
+
+		if remoteDoc.ETag != removed.ETag && options.Resolution != ConflictForce {
+			report.State = StateConflict
+			report.ETag = remoteDoc.ETag
+			op.reportDocument(report)
+			op.printDocumentLine(removed.NameLocal, "Error: not deleted because it was changed by another computer (use \"pit push --force\" to delete it)")
+			remaining = append(remaining, removed)
+			continue
+		}
+
+		if removed.Archive {
+			archiveFileName := getArchiveFileName(*props, removed.NameLocal)
+			_, err = backend.Copy(op.ctx, remoteFileName, archiveFileName, accessConditions{})
+			if err != nil {
+				report.State = StateError
+				report.Error = err.Error()
+				op.reportDocument(report)
+				op.printDocumentLine(removed.NameLocal, "Error: unable to archive %s\n%s", backend.URL(remoteFileName), err)
+				remaining = append(remaining, removed)
+				continue
+			}
+			op.printDocumentLine(removed.NameLocal, "archived as %s", backend.URL(archiveFileName))
+		}
+
+		// Only delete the version that was checked (and archived), not one uploaded by another computer since.
+		err = backend.Delete(op.ctx, remoteFileName, accessConditions{IfMatch: remoteDoc.ETag})
+		if errors.Is(err, ErrConflict) {
+			report.State = StateConflict
+			op.reportDocument(report)
+			op.printDocumentLine(removed.NameLocal, "Error: not deleted because it was changed by another computer (use \"pit push --force\" to delete it)")
+			remaining = append(remaining, removed)
+			continue
+		} else if err != nil && !errors.Is(err, ErrNotFound) {
+			report.State = StateError
+			report.Error = err.Error()
+			op.reportDocument(report)
+			op.printDocumentLine(removed.NameLocal, "Error: unable to delete %s\n%s", backend.URL(remoteFileName), err)
+			remaining = append(remaining, removed)
+			continue
+		}
+		op.reportDocument(report)
+		op.printDocumentLine(removed.NameLocal, "deleted from %s", backend.URL(remoteFileName))
+
+		for _, alias := range removed.Aliases {
+			err = backend.Delete(op.ctx, getRemoteFileName(*props, alias), accessConditions{})
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				op.printDocumentLine(alias, "Error: unable to delete alias %s\n%s", backend.URL(getRemoteFileName(*props, alias)), err)
+			}
+		}
+	}
+
+	modified := len(remaining) != len(props.Removed)
+	props.Removed = remaining
+	return modified
+}
@@ -0,0 +1,202 @@
+package pit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Pushes intro.mp4 and outro.mp4 and returns the collection.
+func setupRemove(t *testing.T, te *testEnvironment) collectionProperties {
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	for _, name := range []string{"intro.mp4", "outro.mp4"} {
+		writeTestFile(t, name, "content of "+name)
+		collectionAdd(te.op, name)
+	}
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	return readTestCollection(t)
+}
+
+func TestRemoveDeletesRemoteDocumentOnPush(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupRemove(t, te)
+	remoteName := getRemoteFileName(props, "intro.mp4")
+
+	captureOutput(t, func() {
+		if err := collectionRemove(te.op, "intro.mp4", false, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if te.server.blob(testContainerName, remoteName) == nil {
+		t.Fatalf("intro.mp4 was deleted before the push")
+	}
+	assertContains(t, captureOutput(t, func() { collectionStatus(te.op) }), "intro.mp4            removed but not deleted remotely")
+
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	assertContains(t, output, "intro.mp4            deleted from")
+	if te.server.blob(testContainerName, remoteName) != nil {
+		t.Errorf("intro.mp4 was not deleted")
+	}
+	if !fileExists("intro.mp4") {
+		t.Errorf("the local file was deleted")
+	}
+
+	props = readTestCollection(t)
+	remoteProps, err := readRemoteCollection(context.Background(), te.backend(t), props.NameRemote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []collectionProperties{props, remoteProps} {
+		if len(p.Documents) != 1 || p.Documents[0].NameLocal != "outro.mp4" || len(p.Removed) != 0 {
+			t.Errorf("unexpected collection %+v", p)
+		}
+	}
+}
+
+func TestRemoveCachedKeepsRemoteDocument(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupRemove(t, te)
+
+	captureOutput(t, func() { collectionRemove(te.op, "intro.mp4", true, false) })
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	if te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4")) == nil {
+		t.Errorf("intro.mp4 was deleted")
+	}
+	if documentIndex(readTestCollection(t), "intro.mp4") >= 0 {
+		t.Errorf("intro.mp4 is still tracked")
+	}
+
+	if err := collectionRemove(te.op, "missing.mp4", false, false); err == nil {
+		t.Errorf("removing a document that is not in the collection did not fail")
+	}
+}
+
+func TestRemoveArchive(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupRemove(t, te)
+
+	captureOutput(t, func() { collectionRemove(te.op, "intro.mp4", false, true) })
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	assertContains(t, output, "intro.mp4            archived as")
+
+	archived := te.server.blob(testContainerName, getArchiveFileName(props, "intro.mp4"))
+	if archived == nil || string(archived.data) != "content of intro.mp4" || archived.metadata[pitMD5tag] != md5String("content of intro.mp4") {
+		t.Errorf("intro.mp4 was not archived: %+v", archived)
+	}
+	if te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4")) != nil {
+		t.Errorf("intro.mp4 was not deleted")
+	}
+}
+
+func TestRemoveChangedByAnotherComputer(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupRemove(t, te)
+	remoteName := getRemoteFileName(props, "intro.mp4")
+
+	captureOutput(t, func() { collectionRemove(te.op, "intro.mp4", false, false) })
+	te.server.touch(testContainerName, remoteName)
+
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	assertContains(t, output, "intro.mp4            Error: not deleted because it was changed by another computer")
+	if te.server.blob(testContainerName, remoteName) == nil || len(readTestCollection(t).Removed) != 1 {
+		t.Fatalf("intro.mp4 was deleted")
+	}
+
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{Resolution: ConflictForce}) })
+	if te.server.blob(testContainerName, remoteName) != nil {
+		t.Errorf("intro.mp4 was not deleted with --force")
+	}
+}
+
+func TestAddAfterRemove(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupRemove(t, te)
+
+	captureOutput(t, func() { collectionRemove(te.op, "intro.mp4", false, false) })
+	writeTestFile(t, "intro.mp4", "new content of intro.mp4")
+	captureOutput(t, func() { collectionAdd(te.op, "intro.mp4") })
+
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	if strings.Contains(output, "Error") {
+		t.Errorf("push failed:\n%s", output)
+	}
+	blob := te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4"))
+	if blob == nil || string(blob.data) != "new content of intro.mp4" {
+		t.Errorf("intro.mp4 was not uploaded again: %+v", blob)
+	}
+}
+
+func TestFileBackendCopy(t *testing.T) {
+	backend, err := newFileBackend(containerProperties{Type: "file", Path: t.TempDir(), Name: "videos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	localName := filepath.Join(t.TempDir(), "intro.mp4")
+	if err := ioutil.WriteFile(localName, []byte("the video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Put(context.Background(), localName, "intro.mp4", accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.SetMetadata(context.Background(), "intro.mp4", map[string]string{pitMD5tag: "abc"}, accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	etag, err := backend.Copy(context.Background(), "intro.mp4", "archive/intro.mp4", accessConditions{IfNoneMatch: etagAny})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteDoc, err := backend.Stat(context.Background(), "archive/intro.mp4")
+	if err != nil || remoteDoc.ETag != etag || remoteDoc.MD5 != "abc" {
+		t.Errorf("unexpected copy %+v %v", remoteDoc, err)
+	}
+	var buffer bytes.Buffer
+	if err := backend.Get(context.Background(), "archive/intro.mp4", 0, &buffer); err != nil || buffer.String() != "the video" {
+		t.Errorf("unexpected content %q %v", buffer.String(), err)
+	}
+
+	if _, err := backend.Copy(context.Background(), "intro.mp4", "archive/intro.mp4", accessConditions{IfNoneMatch: etagAny}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a version conflict, got %v", err)
+	}
+}
+
+// A document that another computer updated after its ETag was checked is not deleted.
+func TestDeleteAccessConditions(t *testing.T) {
+	te := newTestEnvironment(t)
+	props := setupRemove(t, te)
+	fileBackend, err := newFileBackend(containerProperties{Type: "file", Path: t.TempDir(), Name: "videos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backend := range []Backend{te.backend(t), fileBackend} {
+		remoteName := getRemoteFileName(props, "intro.mp4")
+		etag, err := backend.Put(context.Background(), "intro.mp4", remoteName, accessConditions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := backend.SetMetadata(context.Background(), remoteName, map[string]string{pitMD5tag: "abc"}, accessConditions{IfMatch: etag}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := backend.Delete(context.Background(), remoteName, accessConditions{IfMatch: etag}); !errors.Is(err, ErrConflict) {
+			t.Errorf("expected a version conflict, got %v", err)
+		}
+		remoteDoc, err := backend.Stat(context.Background(), remoteName)
+		if err != nil {
+			t.Fatalf("the updated document was deleted: %v", err)
+		}
+		if err := backend.Delete(context.Background(), remoteName, accessConditions{IfMatch: remoteDoc.ETag}); err != nil {
+			t.Error(err)
+		}
+		if err := backend.Delete(context.Background(), remoteName, accessConditions{}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+/*
+	Operations that are run by scripts and CI (status, push, pull, clone, and fetch) record the outcome of every
+	document in a Report, which is returned by the API (see api.go). The pit command writes it as a single JSON
+	object with "--json" and as one tab-separated line per document with "--porcelain", instead of the text output.
+	The JSON field names and the states below are part of the interface that scripts rely on, so only add to them.
+
+	{
+	    "command": "status",
+	    "collection": "videos",
+	    "documents": [
+	        {"name": "intro.mp4", "state": "verified", "localMD5": "...", "remoteMD5": "...", "etag": "...", "url": "..."}
+	    ],
+	    "summary": {"verified": 1},
+	    "failed": false
+	}
+*/
+package pit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A DocumentState is the state of a document as reported by Collection.Status(), Collection.Push(),
+// Collection.Pull(), Collection.Fetch(), and Clone().
+type DocumentState string
+
+const (
+	StateVerified   DocumentState = "verified"    // Local and remote documents are the same.
+	StateModified   DocumentState = "modified"    // Changed locally but not added with "pit add".
+	StateUnpushed   DocumentState = "unpushed"    // Added but not pushed.
+	StateConflict   DocumentState = "conflict"    // Changed by another computer.
+	StateMissing    DocumentState = "missing"     // In the collection but not in the collection folder.
+	StateRemoteOnly DocumentState = "remote-only" // Not downloaded by "pit clone --only".
+	StateUntracked  DocumentState = "untracked"   // In the collection folder but not in the collection.
+	StateRemoved    DocumentState = "removed"     // Removed with "pit rm".
+	StateRenamed    DocumentState = "renamed"     // Renamed with "pit mv" but not pushed.
+	StateError      DocumentState = "error"
+)
+
+// A DocumentReport is the outcome of an operation for one document.
+type DocumentReport struct {
+	Name      string        `json:"name"`
+	State     DocumentState `json:"state"`
+	LocalMD5  string        `json:"localMD5,omitempty"`
+	RemoteMD5 string        `json:"remoteMD5,omitempty"`
+	ETag      string        `json:"etag,omitempty"`
+	URL       string        `json:"url,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// A Report is the outcome of an operation for every document, with the number of documents in each state.
+type Report struct {
+	Command    string                `json:"command"`
+	Collection string                `json:"collection,omitempty"`
+	Documents  []DocumentReport      `json:"documents"`
+	Summary    map[DocumentState]int `json:"summary"`
+	Error      string                `json:"error,omitempty"` // Set if the operation failed as a whole.
+	Failed     bool                  `json:"failed"`              // True if the operation failed for any document.
+
+	err error // The error of the operation as a whole, returned by the API.
+}
+
+// Records the outcome of a document. Documents are pushed and cloned by several workers, so this can be called
+// concurrently.
+func (op *operation) reportDocument(doc DocumentReport) {
+	op.reportMutex.Lock()
+	defer op.reportMutex.Unlock()
+
+	if op.report != nil {
+		op.report.Documents = append(op.report.Documents, doc)
+	}
+}
+
+// Records the name of the collection the operation runs in.
+func (op *operation) reportCollection(name string) {
+	op.reportMutex.Lock()
+	defer op.reportMutex.Unlock()
+
+	if op.report != nil {
+		op.report.Collection = name
+	}
+}
+
+// Records an error of the operation as a whole, e.g. the collection could not be read.
+func (op *operation) reportCommandError(err error) {
+	op.reportMutex.Lock()
+	defer op.reportMutex.Unlock()
+
+	if op.report != nil {
+		op.report.Error = err.Error()
+		op.report.err = err
+	}
+}
+
+// Returns true if a document could not be verified, pushed, or downloaded.
+func (report *Report) failed() bool {
+	if len(report.Error) != 0 {
+		return true
+	}
+	for _, doc := range report.Documents {
+		if doc.State == StateError || doc.State == StateConflict {
+			return true
+		}
+	}
+	return false
+}
+
+// Stops recording and returns the report with its summary.
+func (op *operation) finishReport() *Report {
+	op.reportMutex.Lock()
+	report := op.report
+	op.report = nil
+	op.reportMutex.Unlock()
+
+	if report == nil {
+		return nil
+	}
+
+	sort.SliceStable(report.Documents, func(i, j int) bool { return report.Documents[i].Name < report.Documents[j].Name })
+	report.Summary = map[DocumentState]int{}
+	for _, doc := range report.Documents {
+		report.Summary[doc.State]++
+	}
+	report.Failed = report.failed()
+	if report.Documents == nil {
+		report.Documents = []DocumentReport{}
+	}
+	return report
+}
+
+// WriteJSON writes the report as a single JSON object.
+func (report *Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// WritePorcelain writes one line per document with the tab-separated fields state, name, local MD5, remote MD5,
+// ETag, URL, and error, followed by a "# summary" line, e.g. "# summary verified=2 unpushed=1 failed=false".
+func (report *Report) WritePorcelain(w io.Writer) error {
+	for _, doc := range report.Documents {
+		fields := []string{string(doc.State), doc.Name, doc.LocalMD5, doc.RemoteMD5, doc.ETag, doc.URL,
+			strings.ReplaceAll(doc.Error, "\n", " ")}
+		fmt.Fprintln(w, strings.Join(fields, "\t"))
+	}
+
+	var states []string
+	for state := range report.Summary {
+		states = append(states, string(state))
+	}
+	sort.Strings(states)
+
+	summary := "# summary"
+	for _, state := range states {
+		summary += fmt.Sprintf(" %s=%d", state, report.Summary[DocumentState(state)])
+	}
+	if len(report.Error) != 0 {
+		fmt.Fprintf(w, "# error %s\n", strings.ReplaceAll(report.Error, "\n", " "))
+	}
+	_, err := fmt.Fprintf(w, "%s failed=%v\n", summary, report.Failed)
+	return err
+}
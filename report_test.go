@@ -0,0 +1,173 @@
+package pit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Runs f like "pit <command> --json" or "pit <command> --porcelain" and returns the output and exit code.
+func runTestReport(t *testing.T, command string, format string, f func(op *operation) error) (string, int) {
+	client := new(Client)
+	report, err := client.run(context.Background(), "", command, f)
+
+	var output strings.Builder
+	if format == "json" {
+		report.WriteJSON(&output)
+	} else {
+		report.WritePorcelain(&output)
+	}
+	if err != nil || report.Failed {
+		return output.String(), 1
+	}
+	return output.String(), 0
+}
+
+func TestStatusJSON(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	for _, name := range []string{"intro.mp4", "outro.mp4", "credits.mp4"} {
+		writeTestFile(t, name, "content of "+name)
+		collectionAdd(te.op, name)
+	}
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	writeTestFile(t, "intro.mp4", "new content of intro.mp4")
+	deleteFile("outro.mp4")
+	writeTestFile(t, "notes.txt", "notes")
+	writeTestFile(t, "syllabus.pdf", "syllabus")
+	collectionAdd(te.op, "syllabus.pdf")
+
+	output, code := runTestReport(t, "status", "json", collectionStatus)
+	var report Report
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("invalid JSON %v:\n%s", err, output)
+	}
+	if code != 0 || report.Failed || report.Command != "status" || report.Collection != "videos" {
+		t.Errorf("unexpected report %+v (exit code %d)", report, code)
+	}
+
+	states := map[string]DocumentState{}
+	for _, doc := range report.Documents {
+		states[doc.Name] = doc.State
+		if doc.Name == "credits.mp4" && (len(doc.URL) == 0 || doc.LocalMD5 != doc.RemoteMD5 || len(doc.ETag) == 0) {
+			t.Errorf("incomplete report %+v", doc)
+		}
+	}
+	expected := map[string]DocumentState{"credits.mp4": StateVerified, "intro.mp4": StateModified, "outro.mp4": StateMissing,
+		"notes.txt": StateUntracked, "syllabus.pdf": StateUnpushed}
+	for name, state := range expected {
+		if states[name] != state {
+			t.Errorf("expected %s to be %s, got %s", name, state, states[name])
+		}
+	}
+	if report.Summary[StateVerified] != 1 || len(report.Documents) != 5 {
+		t.Errorf("unexpected summary %v", report.Summary)
+	}
+}
+
+func TestPushPorcelainFails(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "version 1")
+	collectionAdd(te.op, "intro.mp4")
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	props := readTestCollection(t)
+
+	// Another computer uploads a version this computer does not know about.
+	writeTestFile(t, "other.mp4", "their version")
+	backend := te.backend(t)
+	remoteName := getRemoteFileName(props, "intro.mp4")
+	if _, err := backend.Put(context.Background(), "other.mp4", remoteName, accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := setDocumentMetadataMD5(context.Background(), backend, remoteName, md5String("their version"), accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+	deleteFile("other.mp4")
+
+	writeTestFile(t, "intro.mp4", "version 2")
+	collectionAdd(te.op, "intro.mp4")
+	output, code := runTestReport(t, "push", "porcelain", func(op *operation) error { return collectionPush(op, PushOptions{}) })
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if strings.Contains(output, "Uploading") {
+		t.Errorf("text output was not discarded:\n%s", output)
+	}
+	assertContains(t, output, "conflict\tintro.mp4\t"+md5String("version 2")+"\t")
+	assertContains(t, output, "# summary conflict=1 failed=true\n")
+}
+
+func TestPushJSONWithoutCollection(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+
+	output, code := runTestReport(t, "push", "json", func(op *operation) error { return collectionPush(op, PushOptions{}) })
+	var report Report
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("invalid JSON %v:\n%s", err, output)
+	}
+	if code != 1 || !report.Failed || len(report.Error) == 0 {
+		t.Errorf("unexpected report %+v (exit code %d)", report, code)
+	}
+}
+
+func TestPullJSONReportsRefusedDocuments(t *testing.T) {
+	te := newTestEnvironment(t)
+	setupRemoteUpdate(t, te)
+	writeTestFile(t, "intro.mp4", "local edit")
+
+	output, code := runTestReport(t, "pull", "json", func(op *operation) error { return collectionPull(op, false) })
+	var report Report
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("invalid JSON %v:\n%s", err, output)
+	}
+	if code != 1 || !report.Failed || report.Collection != "videos" {
+		t.Errorf("unexpected report %+v (exit code %d)", report, code)
+	}
+
+	states := map[string]DocumentState{}
+	for _, doc := range report.Documents {
+		states[doc.Name] = doc.State
+	}
+	if states["intro.mp4"] != StateConflict || states["notes.pdf"] != StateVerified || len(report.Documents) != 2 {
+		t.Errorf("unexpected documents %+v", report.Documents)
+	}
+}
+
+func TestFetchPorcelainReportsUnverifiedDownload(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	for _, name := range []string{"intro.mp4", "outro.mp4"} {
+		writeTestFile(t, name, "content of "+name)
+		collectionAdd(te.op, name)
+	}
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	props := readTestCollection(t)
+
+	// The remote document is replaced without updating its pitmd5 metadata.
+	backend := te.backend(t)
+	remoteName := getRemoteFileName(props, "outro.mp4")
+	writeTestFile(t, "replacement.mp4", "a replacement")
+	if _, err := backend.Put(context.Background(), "replacement.mp4", remoteName, accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := setDocumentMetadataMD5(context.Background(), backend, remoteName, md5String("content of outro.mp4"), accessConditions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	te.chdirComputer("computer2")
+	captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{Only: []string{"intro.mp4"}}) })
+	te.chdirCloned()
+	output, code := runTestReport(t, "fetch", "porcelain", func(op *operation) error { return collectionFetch(op, []string{"*.mp4"}, 1) })
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	assertContains(t, output, "verified\tintro.mp4\t")
+	assertContains(t, output, "error\toutro.mp4\t")
+	assertContains(t, output, "# summary error=1 verified=1 failed=true\n")
+}
@@ -0,0 +1,567 @@
+/*
+	The s3 backend stores the documents of a Container in an S3-compatible bucket (AWS S3, MinIO, etc.). It is
+	selected with containerProperties.Type "s3" and uses the following containerProperties fields:
+
+	    Endpoint  Example: "https://s3.us-west-2.amazonaws.com" or "http://127.0.0.1:9000" (MinIO)
+	    Region    Example: "us-west-2" (defaults to "us-east-1")
+	    Name      Bucket name
+	    Account   Access key ID (or the AWS_ACCESS_KEY_ID environment variable)
+	    Key       Secret access key (or the AWS_SECRET_ACCESS_KEY environment variable)
+
+	Requests use path-style URLs (<Endpoint>/<bucket>/<remote-name>) and are signed with AWS Signature Version 4.
+	The pitmd5 metadata is stored as the x-amz-meta-pitmd5 header. Note that S3 does not change the ETag when only
+	the metadata changes, and that the ETag of a multipart upload is not an MD5, which is why pit relies on pitmd5.
+
+	Documents are uploaded with a single PUT request, which S3 limits to 5 GB. Larger documents are refused before
+	anything is uploaded since the backend does not implement multipart uploads.
+
+	References:
+	https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+	https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectsV2.html
+	https://docs.aws.amazon.com/AmazonS3/latest/API/API_CopyObject.html
+*/
+package pit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const s3DefaultRegion = "us-east-1"
+const s3MetadataPrefix = "X-Amz-Meta-"
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+const s3MaxPutSize = 5 * 1024 * 1024 * 1024 // The largest object that can be uploaded with a single PUT request.
+
+type s3Backend struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// An error returned by the S3 service.
+type s3Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (se *s3Error) Error() string {
+	if len(se.Code) == 0 {
+		return fmt.Sprintf("S3 request failed with status %d", se.StatusCode)
+	}
+	return fmt.Sprintf("S3 request failed with status %d %s: %s", se.StatusCode, se.Code, se.Message)
+}
+
+// Classifies the error as ErrNotFound, ErrContainerMissing, ErrAuth, or ErrThrottled for errors.Is(). Failed access
+// conditions are translated into a ConflictError by s3ConditionError().
+func (se *s3Error) Is(target error) bool {
+	switch se.Code {
+	case "NoSuchBucket":
+		return target == ErrContainerMissing || target == ErrNotFound
+	case "SlowDown":
+		return target == ErrThrottled
+	}
+
+	statusErr := httpStatusError(se.StatusCode)
+	return statusErr != nil && statusErr != ErrConflict && target == statusErr
+}
+
+func newS3Backend(container containerProperties) (*s3Backend, error) {
+	backend, err := newS3BucketBackend(container)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.accessKey, backend.secretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if len(backend.accessKey) == 0 || len(backend.secretKey) == 0 {
+		backend.accessKey, backend.secretKey = container.Account, container.Key
+	}
+	if len(backend.accessKey) == 0 || len(backend.secretKey) == 0 {
+		return nil, fmt.Errorf("%w: either the AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY environment variable is not set", ErrAuth)
+	}
+	return backend, nil
+}
+
+// Returns the backend of the bucket without credentials, which is enough to know its URL.
+func newS3BucketBackend(container containerProperties) (*s3Backend, error) {
+	backend := new(s3Backend)
+	backend.bucket = container.Name
+	backend.region = container.Region
+	if len(backend.region) == 0 {
+		backend.region = s3DefaultRegion
+	}
+
+	endpoint := container.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", backend.region)
+	}
+
+	var err error
+	backend.endpoint, err = url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if len(backend.endpoint.Scheme) == 0 || len(backend.endpoint.Host) == 0 {
+		return nil, errors.New(fmt.Sprintf("S3 endpoint \"%s\" is not a valid URL", endpoint))
+	}
+
+	backend.client = &http.Client{}
+	return backend, nil
+}
+
+// Encodes s as required by Signature Version 4: every byte except the unreserved characters is percent encoded.
+func s3Encode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, s3Encode(k, true)+"="+s3Encode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Adds the Signature Version 4 Authorization header to req. The host, content-type, range, and all x-amz-* headers
+// are signed.
+func s3Sign(req *http.Request, region string, accessKey string, secretKey string, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lowerName := strings.ToLower(name)
+		if strings.HasPrefix(lowerName, "x-amz-") || lowerName == "content-type" || lowerName == "range" {
+			headers[lowerName] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	var headerNames []string
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3Encode(req.URL.Path, false),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// Returns the URL of remoteName, or of the bucket itself when remoteName is empty.
+func (sb *s3Backend) objectURL(remoteName string) *url.URL {
+	u := *sb.endpoint
+	u.Path = sb.endpoint.Path + "/" + sb.bucket
+	if len(remoteName) != 0 {
+		u.Path += "/" + remoteName
+	}
+	u.RawPath = s3Encode(u.Path, false)
+	return &u
+}
+
+// Sends a signed request with an optional in-memory body.
+func (sb *s3Backend) do(ctx context.Context, method string, u *url.URL, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+
+	return sb.send(req, header, sha256Hex(body))
+}
+
+// Sends a signed request with a file body. The payload is not signed so that large documents are not read twice.
+func (sb *s3Backend) doFile(ctx context.Context, method string, u *url.URL, header http.Header, file *os.File) (*http.Response, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), file)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = info.Size()
+
+	return sb.send(req, header, s3UnsignedPayload)
+}
+
+func (sb *s3Backend) send(req *http.Request, header http.Header, payloadHash string) (*http.Response, error) {
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	s3Sign(req, sb.region, sb.accessKey, sb.secretKey, payloadHash, time.Now())
+	resp, err := sb.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, s3ResponseError(resp)
+	}
+
+	return resp, nil
+}
+
+func s3ResponseError(resp *http.Response) error {
+	serr := &s3Error{StatusCode: resp.StatusCode}
+	data, _ := ioutil.ReadAll(resp.Body)
+	var body struct {
+		Code    string
+		Message string
+	}
+	if xml.Unmarshal(data, &body) == nil {
+		serr.Code = body.Code
+		serr.Message = body.Message
+	}
+	return serr
+}
+
+// Returns a ConflictError when the access conditions of the request were not met.
+func s3ConditionError(err error, remoteName string) error {
+	var serr *s3Error
+	if errors.As(err, &serr) && (serr.StatusCode == http.StatusPreconditionFailed || serr.Code == "ConditionalRequestConflict") {
+		return &ConflictError{RemoteName: remoteName, Err: err}
+	}
+	return err
+}
+
+func s3SetAccessConditions(header http.Header, prefix string, conditions accessConditions) {
+	if len(conditions.IfMatch) != 0 {
+		header.Set(prefix+"If-Match", "\""+conditions.IfMatch+"\"")
+	}
+	if len(conditions.IfNoneMatch) != 0 {
+		header.Set(prefix+"If-None-Match", conditions.IfNoneMatch)
+	}
+}
+
+func (sb *s3Backend) createBucket(ctx context.Context) error {
+	var body []byte
+	if sb.region != s3DefaultRegion {
+		body = []byte("<CreateBucketConfiguration xmlns=\"http://s3.amazonaws.com/doc/2006-03-01/\">" +
+			"<LocationConstraint>" + sb.region + "</LocationConstraint></CreateBucketConfiguration>")
+	}
+
+	resp, err := sb.do(ctx, http.MethodPut, sb.objectURL(""), http.Header{}, body)
+	if err != nil {
+		var serr *s3Error
+		if errors.As(err, &serr) && (serr.Code == "BucketAlreadyOwnedByYou" || serr.Code == "BucketAlreadyExists") {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (sb *s3Backend) Put(ctx context.Context, localName string, remoteName string, conditions accessConditions) (string, error) {
+	header := http.Header{}
+	if ct := contentType(remoteName); len(ct) != 0 {
+		header.Set("Content-Type", ct)
+	}
+	s3SetAccessConditions(header, "", conditions)
+
+	info, err := os.Stat(localName)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > s3MaxPutSize {
+		return "", errors.New(fmt.Sprintf("\"%s\" is %d bytes, S3 documents larger than 5 GB are not supported",
+			remoteName, info.Size()))
+	}
+
+	for attempt := 0; ; attempt++ {
+		file, err := os.Open(localName)
+		if err != nil {
+			return "", err
+		}
+
+		resp, err := sb.doFile(ctx, http.MethodPut, sb.objectURL(remoteName), header, file)
+		file.Close()
+		if err == nil {
+			resp.Body.Close()
+			return strings.Trim(resp.Header.Get("ETag"), "\""), nil
+		}
+
+		// Create the bucket on the first upload, as uploadDocument() used to do for Azure containers.
+		if attempt == 0 && errors.Is(err, ErrContainerMissing) {
+			err = sb.createBucket(ctx)
+			if err == nil {
+				continue
+			}
+		}
+		return "", s3ConditionError(err, remoteName)
+	}
+}
+
+func (sb *s3Backend) Get(ctx context.Context, remoteName string, offset int64, w io.Writer) error {
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := sb.do(ctx, http.MethodGet, sb.objectURL(remoteName), header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (sb *s3Backend) Stat(ctx context.Context, remoteName string) (remoteDocument, error) {
+	var remoteDoc remoteDocument
+	resp, err := sb.do(ctx, http.MethodHead, sb.objectURL(remoteName), http.Header{}, nil)
+	if err != nil {
+		return remoteDoc, sb.headError(ctx, err)
+	}
+	resp.Body.Close()
+
+	remoteDoc.Name = remoteName
+	remoteDoc.ETag = strings.Trim(resp.Header.Get("ETag"), "\"")
+	remoteDoc.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	remoteDoc.Size = resp.ContentLength
+	remoteDoc.Metadata = map[string]string{}
+	for name, values := range resp.Header {
+		if strings.HasPrefix(name, s3MetadataPrefix) && len(values) > 0 {
+			remoteDoc.Metadata[strings.ToLower(strings.TrimPrefix(name, s3MetadataPrefix))] = values[0]
+		}
+	}
+	remoteDoc.MD5 = remoteDoc.Metadata[pitMD5tag]
+	return remoteDoc, nil
+}
+
+// The response to a HEAD request has no body, so a missing object and a missing bucket are both a 404 without an
+// error code. Returns a NoSuchBucket error if a HEAD request of the bucket also fails with 404.
+func (sb *s3Backend) headError(ctx context.Context, err error) error {
+	var serr *s3Error
+	if !errors.As(err, &serr) || serr.StatusCode != http.StatusNotFound || len(serr.Code) != 0 {
+		return err
+	}
+
+	resp, bucketErr := sb.do(ctx, http.MethodHead, sb.objectURL(""), http.Header{}, nil)
+	if bucketErr == nil {
+		resp.Body.Close()
+		return &s3Error{StatusCode: http.StatusNotFound, Code: "NoSuchKey", Message: "The specified key does not exist."}
+	} else if errors.As(bucketErr, &serr) && serr.StatusCode == http.StatusNotFound {
+		return &s3Error{StatusCode: http.StatusNotFound, Code: "NoSuchBucket", Message: "The specified bucket does not exist."}
+	}
+	return err
+}
+
+func (sb *s3Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	continuationToken := ""
+	for {
+		u := sb.objectURL("")
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if len(continuationToken) != 0 {
+			query.Set("continuation-token", continuationToken)
+		}
+		u.RawQuery = s3CanonicalQuery(query)
+
+		resp, err := sb.do(ctx, http.MethodGet, u, http.Header{}, nil)
+		if errors.Is(err, ErrNotFound) {
+			log.Println(fmt.Sprintf("Container \"%s\" not found", sb.bucket))
+			return names, nil
+		} else if err != nil {
+			return names, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return names, err
+		}
+
+		for _, object := range result.Contents {
+			names = append(names, object.Key)
+		}
+
+		if !result.IsTruncated || len(result.NextContinuationToken) == 0 {
+			return names, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+func (sb *s3Backend) Delete(ctx context.Context, remoteName string, conditions accessConditions) error {
+	// S3 reports success when deleting a missing object so check that it exists first.
+	remoteDoc, err := sb.Stat(ctx, remoteName)
+	if err != nil {
+		return err
+	}
+	if len(conditions.IfMatch) != 0 && remoteDoc.ETag != conditions.IfMatch {
+		return &ConflictError{RemoteName: remoteName, Err: errors.New("ETag does not match")}
+	}
+
+	// The If-Match header makes the check atomic on services that support conditional deletes.
+	header := http.Header{}
+	s3SetAccessConditions(header, "", accessConditions{IfMatch: conditions.IfMatch})
+	resp, err := sb.do(ctx, http.MethodDelete, sb.objectURL(remoteName), header, nil)
+	if err != nil {
+		return s3ConditionError(err, remoteName)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (sb *s3Backend) Copy(ctx context.Context, sourceRemoteName string, remoteName string, conditions accessConditions) (string, error) {
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", s3Encode("/"+sb.bucket+"/"+sourceRemoteName, false))
+	header.Set("X-Amz-Metadata-Directive", "COPY")
+	s3SetAccessConditions(header, "", conditions)
+	return sb.copyObject(ctx, remoteName, header)
+}
+
+func (sb *s3Backend) SetMetadata(ctx context.Context, remoteName string, metadata map[string]string, conditions accessConditions) (string, error) {
+	remoteDoc, err := sb.Stat(ctx, remoteName)
+	if err != nil {
+		return "", err
+	}
+	if len(conditions.IfMatch) != 0 && remoteDoc.ETag != conditions.IfMatch {
+		return "", &ConflictError{RemoteName: remoteName, Err: errors.New("ETag does not match")}
+	}
+
+	for k, v := range metadata {
+		remoteDoc.Metadata[k] = v
+	}
+
+	// S3 metadata can only be replaced by copying the object onto itself.
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", s3Encode("/"+sb.bucket+"/"+remoteName, false))
+	header.Set("X-Amz-Metadata-Directive", "REPLACE")
+	s3SetAccessConditions(header, "X-Amz-Copy-Source-", conditions)
+	if ct := contentType(remoteName); len(ct) != 0 {
+		header.Set("Content-Type", ct)
+	}
+	for k, v := range remoteDoc.Metadata {
+		header.Set(s3MetadataPrefix+k, v)
+	}
+
+	return sb.copyObject(ctx, remoteName, header)
+}
+
+// Copies an object as described by the X-Amz-Copy-Source header and returns the ETag of the copy.
+func (sb *s3Backend) copyObject(ctx context.Context, remoteName string, header http.Header) (string, error) {
+	resp, err := sb.do(ctx, http.MethodPut, sb.objectURL(remoteName), header, nil)
+	if err != nil {
+		return "", s3ConditionError(err, remoteName)
+	}
+	defer resp.Body.Close()
+
+	// A copy can fail after the 200 status has been sent, in which case the body contains an Error element.
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if bytes.Contains(data, []byte("<Error>")) {
+		serr := &s3Error{StatusCode: resp.StatusCode}
+		var body struct {
+			Code    string
+			Message string
+		}
+		_ = xml.Unmarshal(data, &body)
+		serr.Code = body.Code
+		serr.Message = body.Message
+		return "", serr
+	}
+
+	var result struct {
+		ETag string
+	}
+	_ = xml.Unmarshal(data, &result)
+	return strings.Trim(result.ETag, "\""), nil
+}
+
+func (sb *s3Backend) URL(remoteName string) string {
+	return sb.objectURL(remoteName).String()
+}
@@ -4,8 +4,8 @@ import subprocess
 # Removing automatic update of build number. We may want to put this back in later.
 # subprocess.run(["python3", "scripts/update-build-number.py"])
 
-print("go build -o pit")
-os.system("go build -o pit")
+print("go build -o pit ./cmd/pit")
+os.system("go build -o pit ./cmd/pit")
 
 print("./pit version")
 os.system("./pit version")
\ No newline at end of file
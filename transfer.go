@@ -0,0 +1,99 @@
+/*
+	Transfers (uploads and downloads) of the documents in a collection run on a bounded pool of workers so that a
+	collection of large videos is not pushed or cloned one document at a time. The number of workers is set with
+	"--jobs N". Output from the workers is serialized so that each line stays intact, and the byte-level download
+	progress is only shown while a single transfer is active.
+*/
+package pit
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// The number of documents transferred at the same time when "--jobs" is not given.
+const defaultJobs = 4
+
+// Calls f for every index in [0, count) using at most jobs goroutines and returns after every call has returned.
+func forEachParallel(count int, jobs int, f func(index int)) {
+	if jobs <= 0 {
+		jobs = defaultJobs
+	}
+	if jobs > count {
+		jobs = count
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < jobs; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				f(index)
+			}
+		}()
+	}
+
+	for index := 0; index < count; index++ {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// ParseJobs parses the value of "--jobs", which must be a number greater than 0.
+func ParseJobs(value string) (int, error) {
+	jobs, err := strconv.Atoi(value)
+	if err != nil || jobs < 1 {
+		return 0, fmt.Errorf("--jobs must be a number greater than 0, not \"%s\"", value)
+	}
+	return jobs, nil
+}
+
+// Prints a line of output without interleaving it with the output of other workers.
+func (op *operation) printLine(format string, a ...interface{}) {
+	op.outputMutex.Lock()
+	defer op.outputMutex.Unlock()
+
+	if op.progressLineOpen {
+		fmt.Fprintln(op.out)
+		op.progressLineOpen = false
+	}
+	fmt.Fprintf(op.out, format+"\n", a...)
+}
+
+// Prints the status of a document, e.g. "intro.mp4            verified and shared as ...".
+func (op *operation) printDocumentLine(nameLocal string, format string, a ...interface{}) {
+	op.printLine("%s "+format, append([]interface{}{padRight(nameLocal, " ", 20)}, a...)...)
+}
+
+func (op *operation) beginDownload() {
+	op.outputMutex.Lock()
+	defer op.outputMutex.Unlock()
+	op.activeDownloads++
+}
+
+func (op *operation) endDownload(localName string, err error) {
+	op.outputMutex.Lock()
+	defer op.outputMutex.Unlock()
+
+	op.activeDownloads--
+	if err == nil {
+		// Trailing spaces are to overwrite previously written progress.
+		fmt.Fprintf(op.out, "\r%s...complete                   \n", localName)
+		op.progressLineOpen = false
+	}
+}
+
+// Prints the number of bytes downloaded so far, unless other downloads are active at the same time.
+func (op *operation) printDownloadProgress(localName string, total uint64) {
+	op.outputMutex.Lock()
+	defer op.outputMutex.Unlock()
+
+	if op.activeDownloads <= 1 {
+		fmt.Fprintf(op.out, "\r%s...%d downloaded (kb)", localName, total/1000)
+		op.progressLineOpen = true
+	}
+}
@@ -0,0 +1,93 @@
+package pit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEachParallelIsBounded(t *testing.T) {
+	var mutex sync.Mutex
+	active, maxActive := 0, 0
+	called := make([]int, 20)
+
+	forEachParallel(len(called), 3, func(index int) {
+		mutex.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		called[index]++
+		mutex.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+	})
+
+	if maxActive > 3 {
+		t.Errorf("expected at most 3 concurrent calls, got %d", maxActive)
+	}
+	for index, count := range called {
+		if count != 1 {
+			t.Errorf("index %d called %d times", index, count)
+		}
+	}
+}
+
+func TestParseJobs(t *testing.T) {
+	if jobs, err := ParseJobs("8"); err != nil || jobs != 8 {
+		t.Errorf("ParseJobs(\"8\") = %d, %v", jobs, err)
+	}
+	for _, value := range []string{"0", "-1", "many"} {
+		if _, err := ParseJobs(value); err == nil {
+			t.Errorf("ParseJobs(\"%s\") did not fail", value)
+		}
+	}
+}
+
+func TestParallelPushAndClone(t *testing.T) {
+	te := newTestEnvironment(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("video%02d.mp4", i)
+		writeTestFile(t, name, "content of "+name)
+		collectionAdd(te.op, name)
+	}
+
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{Jobs: 5}) })
+	props := readTestCollection(t)
+	for _, doc := range props.Documents {
+		assertContains(t, output, "Uploading: "+doc.NameLocal+"...")
+		blob := te.server.blob(testContainerName, getRemoteFileName(props, doc.NameLocal))
+		if blob == nil || string(blob.data) != "content of "+doc.NameLocal || len(doc.ETag) == 0 {
+			t.Errorf("%s was not pushed", doc.NameLocal)
+		}
+	}
+
+	// The manifest is uploaded once every document has been pushed, so it records every ETag.
+	remoteProps, err := readRemoteCollection(context.Background(), te.backend(t), props.NameRemote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, remoteDoc := range remoteProps.Documents {
+		if len(remoteDoc.ETag) == 0 {
+			t.Errorf("manifest does not record the ETag of %s", remoteDoc.NameLocal)
+		}
+	}
+
+	te.chdirComputer("computer2")
+	output = captureOutput(t, func() { collectionClone(te.op, "videos", "", CloneOptions{Jobs: 5}) })
+	te.chdirCloned()
+	for _, doc := range props.Documents {
+		assertContains(t, output, doc.NameLocal+"...complete")
+		if readTestFile(t, doc.NameLocal) != "content of "+doc.NameLocal {
+			t.Errorf("%s was not cloned", doc.NameLocal)
+		}
+	}
+}
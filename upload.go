@@ -0,0 +1,192 @@
+/*
+	Documents larger than uploadBlockSize are uploaded in blocks when the Backend supports it (see blockBackend).
+	The IDs of the blocks staged so far are recorded in the collection's local state (.pit.uploads.json), so when
+	an upload is interrupted (e.g. the network fails or the laptop sleeps) a re-run of "pit push" only stages the
+	missing blocks and then commits the block list. The recorded blocks are only reused if the local file has not
+	changed since they were staged. The block IDs start with a random nonce of the upload, so blocks that another
+	computer stages for the same document never replace, or get committed as, the blocks of this upload.
+*/
+package pit
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// The size of the blocks in which documents are uploaded. Azure allows at most 50,000 blocks per document.
+var uploadBlockSize int64 = 8 * 1024 * 1024
+
+// An upload that has not been committed yet.
+type pendingUpload struct {
+	LocalName string
+	Size      int64
+	Modified  time.Time
+	BlockSize int64
+	Nonce     string   // The prefix of the block IDs of the upload, e.g. "3f9a0c1e".
+	BlockIDs  []string // The IDs of the blocks that have been staged.
+}
+
+type uploadState struct {
+	Uploads map[string]pendingUpload // Pending uploads by remote name.
+}
+
+// Serializes access to the upload state as documents are uploaded by several workers.
+var uploadStateMutex sync.Mutex
+
+func uploadStateRead(op *operation) uploadState {
+	var state uploadState
+	data, err := ioutil.ReadFile(op.path(pitUploadsFileName))
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+		if err != nil {
+			log.Println(fmt.Sprintf("Ignoring invalid %s: %s", pitUploadsFileName, err))
+		}
+	}
+	if state.Uploads == nil {
+		state.Uploads = map[string]pendingUpload{}
+	}
+	return state
+}
+
+func uploadStateWrite(op *operation, state uploadState) error {
+	if len(state.Uploads) == 0 {
+		deleteFile(op.path(pitUploadsFileName))
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(op.path(pitUploadsFileName), data, 0644)
+}
+
+// Updates the pending upload of remoteName, or removes it if upload is nil.
+func uploadStateUpdate(op *operation, remoteName string, upload *pendingUpload) error {
+	uploadStateMutex.Lock()
+	defer uploadStateMutex.Unlock()
+
+	state := uploadStateRead(op)
+	if upload == nil {
+		delete(state.Uploads, remoteName)
+	} else {
+		state.Uploads[remoteName] = *upload
+	}
+	return uploadStateWrite(op, state)
+}
+
+// Returns a random nonce for the block IDs of a new upload.
+func newUploadNonce() (string, error) {
+	nonce := make([]byte, 4)
+	_, err := rand.Read(nonce)
+	return hex.EncodeToString(nonce), err
+}
+
+// Returns the ID of the block at index, e.g. "pit-3f9a0c1e-00012". All IDs have the same length as required by
+// Azure, which is also the length of the "pit-block-00000012" IDs of earlier versions.
+func uploadBlockID(nonce string, index int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("pit-%s-%05d", nonce, index)))
+}
+
+// Returns the pending upload of the local file, which is a new upload without staged blocks if the file changed
+// since its blocks were staged.
+func pendingUploadFor(op *operation, backend blockBackend, localName string, remoteName string, info os.FileInfo) (pendingUpload, error) {
+	uploadStateMutex.Lock()
+	recorded, ok := uploadStateRead(op).Uploads[remoteName]
+	uploadStateMutex.Unlock()
+
+	upload := pendingUpload{LocalName: localName, Size: info.Size(), Modified: info.ModTime(), BlockSize: uploadBlockSize}
+	if !ok || recorded.LocalName != localName || recorded.Size != upload.Size || !recorded.Modified.Equal(upload.Modified) ||
+		recorded.BlockSize != upload.BlockSize || len(recorded.Nonce) == 0 {
+		var err error
+		upload.Nonce, err = newUploadNonce()
+		return upload, err
+	}
+	upload.Nonce = recorded.Nonce
+
+	// Only reuse blocks that are still staged, e.g. Azure discards uncommitted blocks after a week.
+	staged, err := backend.StagedBlocks(op.ctx, remoteName)
+	if err != nil {
+		log.Println(fmt.Sprintf("Unable to list the staged blocks of %s: %s", remoteName, err))
+		return upload, nil
+	}
+	for _, blockID := range recorded.BlockIDs {
+		for _, stagedID := range staged {
+			if blockID == stagedID {
+				upload.BlockIDs = append(upload.BlockIDs, blockID)
+				break
+			}
+		}
+	}
+	return upload, nil
+}
+
+// Uploads the local file in blocks, skipping the blocks staged by an earlier interrupted upload, and commits the
+// block list. Returns the new ETag.
+func uploadDocumentInBlocks(op *operation, backend blockBackend, localName string, remoteName string, conditions accessConditions) (string, error) {
+	file, err := os.Open(op.path(localName))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	upload, err := pendingUploadFor(op, backend, localName, remoteName, info)
+	if err != nil {
+		return "", err
+	}
+	staged := map[string]bool{}
+	for _, blockID := range upload.BlockIDs {
+		staged[blockID] = true
+	}
+
+	blockCount := int((info.Size() + upload.BlockSize - 1) / upload.BlockSize)
+	if len(staged) > 0 {
+		op.printDocumentLine(localName, "resuming upload (%d of %d blocks already uploaded)", len(staged), blockCount)
+	}
+
+	blockIDs := make([]string, blockCount)
+	data := make([]byte, upload.BlockSize)
+	for index := 0; index < blockCount; index++ {
+		blockIDs[index] = uploadBlockID(upload.Nonce, index)
+		if staged[blockIDs[index]] {
+			continue
+		}
+
+		n, err := file.ReadAt(data, int64(index)*upload.BlockSize)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+
+		err = backend.StageBlock(op.ctx, remoteName, blockIDs[index], data[:n])
+		if err != nil {
+			return "", err
+		}
+
+		upload.BlockIDs = append(upload.BlockIDs, blockIDs[index])
+		err = uploadStateUpdate(op, remoteName, &upload)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	etag, err := backend.CommitBlocks(op.ctx, remoteName, blockIDs, conditions)
+	if err != nil {
+		return "", err
+	}
+
+	return etag, uploadStateUpdate(op, remoteName, nil)
+}
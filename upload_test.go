@@ -0,0 +1,191 @@
+package pit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Uploads documents in 4 byte blocks for the duration of the test.
+func useSmallUploadBlocks(t *testing.T) {
+	blockSize := uploadBlockSize
+	uploadBlockSize = 4
+	t.Cleanup(func() { uploadBlockSize = blockSize })
+}
+
+// A blockBackend whose uploads fail after a number of blocks have been staged, as if the network failed.
+type interruptingBackend struct {
+	blockBackend
+	remaining int
+}
+
+func (ib *interruptingBackend) StageBlock(ctx context.Context, remoteName string, blockID string, data []byte) error {
+	if ib.remaining == 0 {
+		return errors.New("connection reset")
+	}
+	ib.remaining--
+	return ib.blockBackend.StageBlock(context.Background(), remoteName, blockID, data)
+}
+
+func TestPushLargeDocumentInBlocks(t *testing.T) {
+	te := newTestEnvironment(t)
+	useSmallUploadBlocks(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "thirty bytes of video content!")
+	collectionAdd(te.op, "intro.mp4")
+
+	captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	props := readTestCollection(t)
+	blob := te.server.blob(testContainerName, getRemoteFileName(props, "intro.mp4"))
+	if blob == nil || string(blob.data) != "thirty bytes of video content!" || blob.contentType != "video/mp4" {
+		t.Fatalf("intro.mp4 was not uploaded in blocks: %+v", blob)
+	}
+	if te.server.stagedBlockCount() != 8 || te.server.verifiedBlockCount() != 8 {
+		t.Errorf("expected 8 staged blocks with a Content-MD5, got %d of which %d with a Content-MD5",
+			te.server.stagedBlockCount(), te.server.verifiedBlockCount())
+	}
+	if fileExists(pitUploadsFileName) {
+		t.Errorf("%s was not removed after the upload was committed", pitUploadsFileName)
+	}
+
+	output := captureOutput(t, func() { collectionStatus(te.op) })
+	assertContains(t, output, "intro.mp4            verified and shared as")
+}
+
+func TestPushResumesInterruptedUpload(t *testing.T) {
+	te := newTestEnvironment(t)
+	useSmallUploadBlocks(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "thirty bytes of video content!")
+	collectionAdd(te.op, "intro.mp4")
+
+	props := readTestCollection(t)
+	remoteName := getRemoteFileName(props, "intro.mp4")
+	backend := &interruptingBackend{blockBackend: te.backend(t).(blockBackend), remaining: 3}
+	if _, err := uploadDocumentInBlocks(te.op, backend, "intro.mp4", remoteName, accessConditions{}); err == nil {
+		t.Fatal("expected the interrupted upload to fail")
+	}
+	if upload := uploadStateRead(te.op).Uploads[remoteName]; len(upload.BlockIDs) != 3 {
+		t.Fatalf("expected 3 staged blocks in %s, got %+v", pitUploadsFileName, upload)
+	}
+
+	// A new push only stages the missing blocks.
+	stagedBefore := te.server.stagedBlockCount()
+	output := captureOutput(t, func() { collectionPush(te.op, PushOptions{}) })
+	assertContains(t, output, "intro.mp4            resuming upload (3 of 8 blocks already uploaded)")
+	if staged := te.server.stagedBlockCount() - stagedBefore; staged != 5 {
+		t.Errorf("expected 5 blocks to be staged by the resumed upload, got %d", staged)
+	}
+	if blob := te.server.blob(testContainerName, remoteName); blob == nil || string(blob.data) != "thirty bytes of video content!" {
+		t.Errorf("resumed upload has unexpected content: %+v", blob)
+	}
+	if fileExists(pitUploadsFileName) {
+		t.Errorf("%s was not removed after the upload was committed", pitUploadsFileName)
+	}
+}
+
+func TestResumedUploadIgnoresBlocksOfOtherComputers(t *testing.T) {
+	te := newTestEnvironment(t)
+	useSmallUploadBlocks(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "thirty bytes of video content!")
+
+	backend := &interruptingBackend{blockBackend: te.backend(t).(blockBackend), remaining: 3}
+	if _, err := uploadDocumentInBlocks(te.op, backend, "intro.mp4", "intro.mp4", accessConditions{}); err == nil {
+		t.Fatal("expected the interrupted upload to fail")
+	}
+	upload := uploadStateRead(te.op).Uploads["intro.mp4"]
+	if len(upload.Nonce) == 0 || upload.BlockIDs[0] != uploadBlockID(upload.Nonce, 0) {
+		t.Fatalf("the nonce of the upload was not recorded: %+v", upload)
+	}
+
+	// Another computer stages blocks of its own version of the document.
+	nonce, err := newUploadNonce()
+	if err != nil || nonce == upload.Nonce {
+		t.Fatalf("unexpected nonce %q %v", nonce, err)
+	}
+	for index := 0; index < 8; index++ {
+		if err := backend.blockBackend.StageBlock(context.Background(), "intro.mp4", uploadBlockID(nonce, index), []byte("XXXX")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend.remaining = -1
+	output := captureOutput(t, func() {
+		if _, err := uploadDocumentInBlocks(te.op, backend, "intro.mp4", "intro.mp4", accessConditions{}); err != nil {
+			t.Error(err)
+		}
+	})
+	assertContains(t, output, "intro.mp4            resuming upload (3 of 8 blocks already uploaded)")
+	if blob := te.server.blob(testContainerName, "intro.mp4"); blob == nil || string(blob.data) != "thirty bytes of video content!" {
+		t.Errorf("unexpected content: %+v", blob)
+	}
+}
+
+func TestUploadRestartsWhenDocumentChanged(t *testing.T) {
+	te := newTestEnvironment(t)
+	useSmallUploadBlocks(t)
+	te.chdir("computer1", "videos")
+	collectionInitialize(te.op)
+	writeTestFile(t, "intro.mp4", "thirty bytes of video content!")
+
+	backend := &interruptingBackend{blockBackend: te.backend(t).(blockBackend), remaining: 3}
+	if _, err := uploadDocumentInBlocks(te.op, backend, "intro.mp4", "intro.mp4", accessConditions{}); err == nil {
+		t.Fatal("expected the interrupted upload to fail")
+	}
+
+	// The blocks staged for the earlier version of the document are not reused.
+	writeTestFile(t, "intro.mp4", "the edited video content")
+	backend.remaining = -1
+	output := captureOutput(t, func() {
+		if _, err := uploadDocumentInBlocks(te.op, backend, "intro.mp4", "intro.mp4", accessConditions{}); err != nil {
+			t.Error(err)
+		}
+	})
+	if strings.Contains(output, "resuming") {
+		t.Errorf("upload of a changed document was resumed:\n%s", output)
+	}
+	if blob := te.server.blob(testContainerName, "intro.mp4"); blob == nil || string(blob.data) != "the edited video content" {
+		t.Errorf("unexpected content: %+v", blob)
+	}
+}
+
+func TestFileBackendBlocks(t *testing.T) {
+	root := t.TempDir()
+	backend, err := newFileBackend(containerProperties{Type: "file", Path: root, Name: "videos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index, data := range []string{"first ", "second"} {
+		if err := backend.StageBlock(context.Background(), "intro.mp4", uploadBlockID("3f9a0c1e", index), []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	staged, err := backend.StagedBlocks(context.Background(), "intro.mp4")
+	sort.Strings(staged)
+	if err != nil || len(staged) != 2 || staged[0] != uploadBlockID("3f9a0c1e", 0) || staged[1] != uploadBlockID("3f9a0c1e", 1) {
+		t.Fatalf("unexpected staged blocks %v %v", staged, err)
+	}
+
+	if _, err := backend.CommitBlocks(context.Background(), "intro.mp4", []string{uploadBlockID("3f9a0c1e", 0), uploadBlockID("3f9a0c1e", 1)}, accessConditions{IfNoneMatch: etagAny}); err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if err := backend.Get(context.Background(), "intro.mp4", 0, &buffer); err != nil || buffer.String() != "first second" {
+		t.Errorf("unexpected content %q %v", buffer.String(), err)
+	}
+	if staged, _ := backend.StagedBlocks(context.Background(), "intro.mp4"); len(staged) != 0 {
+		t.Errorf("staged blocks were not removed: %v", staged)
+	}
+	if names, _ := backend.List(context.Background(), ""); len(names) != 1 || names[0] != "intro.mp4" {
+		t.Errorf("unexpected documents %v", names)
+	}
+}
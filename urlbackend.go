@@ -0,0 +1,142 @@
+/*
+	The URL backend reads the documents of a Container that is not in the user's account.json over plain HTTP(S),
+	e.g. a public Azure container or S3 bucket shared by a colleague as a manifest URL
+	("https://pithub.blob.core.windows.net/nvm4zqwm/<remote-name>.json"). It is selected by the URL of a collection
+	and is read-only, so documents can be cloned and pulled but not pushed.
+*/
+package pit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var errReadOnlyContainer = errors.New("Container is read-only")
+
+type urlBackend struct {
+	containerURL string // Without a trailing "/".
+	client       *http.Client
+}
+
+func newURLBackend(containerURL string) *urlBackend {
+	backend := new(urlBackend)
+	backend.containerURL = strings.TrimSuffix(containerURL, "/")
+	backend.client = http.DefaultClient
+	return backend
+}
+
+func (ub *urlBackend) readOnlyError(remoteName string) error {
+	return fmt.Errorf("%w: %s is not in %s so %s cannot be changed", errReadOnlyContainer, ub.containerURL,
+		userAppAccountFileName, remoteName)
+}
+
+func (ub *urlBackend) do(ctx context.Context, method string, remoteName string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, ub.URL(remoteName), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := ub.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, remoteName)
+	} else if statusErr := httpStatusError(resp.StatusCode); statusErr != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: unable to read %s: %s", statusErr, ub.URL(remoteName), resp.Status)
+	} else if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, errors.New(fmt.Sprintf("unable to read %s: %s", ub.URL(remoteName), resp.Status))
+	}
+	return resp, nil
+}
+
+func (ub *urlBackend) Put(ctx context.Context, localName string, remoteName string, conditions accessConditions) (string, error) {
+	return "", ub.readOnlyError(remoteName)
+}
+
+func (ub *urlBackend) Get(ctx context.Context, remoteName string, offset int64, w io.Writer) error {
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := ub.do(ctx, http.MethodGet, remoteName, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A server that ignores the Range header sends the whole document.
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		_, err = io.CopyN(ioutil.Discard, resp.Body, offset)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (ub *urlBackend) Stat(ctx context.Context, remoteName string) (remoteDocument, error) {
+	var remoteDoc remoteDocument
+	resp, err := ub.do(ctx, http.MethodHead, remoteName, http.Header{})
+	if err != nil {
+		return remoteDoc, err
+	}
+	resp.Body.Close()
+
+	remoteDoc.Name = remoteName
+	remoteDoc.ETag = strings.Trim(resp.Header.Get("ETag"), "\"")
+	remoteDoc.Size = resp.ContentLength
+	remoteDoc.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	remoteDoc.Metadata = map[string]string{}
+	for name, values := range resp.Header {
+		// Azure returns metadata as x-ms-meta-<name> and S3 as x-amz-meta-<name>.
+		lowerName := strings.ToLower(name)
+		for _, prefix := range []string{"x-ms-meta-", "x-amz-meta-"} {
+			if strings.HasPrefix(lowerName, prefix) && len(values) > 0 {
+				remoteDoc.Metadata[strings.TrimPrefix(lowerName, prefix)] = values[0]
+			}
+		}
+	}
+	remoteDoc.MD5 = remoteDoc.Metadata[pitMD5tag]
+	return remoteDoc, nil
+}
+
+func (ub *urlBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	return nil, errors.New(fmt.Sprintf("Documents in %s cannot be listed", ub.containerURL))
+}
+
+func (ub *urlBackend) Delete(ctx context.Context, remoteName string, conditions accessConditions) error {
+	return ub.readOnlyError(remoteName)
+}
+
+func (ub *urlBackend) Copy(ctx context.Context, sourceRemoteName string, remoteName string, conditions accessConditions) (string, error) {
+	return "", ub.readOnlyError(remoteName)
+}
+
+func (ub *urlBackend) SetMetadata(ctx context.Context, remoteName string, metadata map[string]string, conditions accessConditions) (string, error) {
+	return "", ub.readOnlyError(remoteName)
+}
+
+func (ub *urlBackend) URL(remoteName string) string {
+	var segments []string
+	for _, segment := range strings.Split(remoteName, "/") {
+		segments = append(segments, url.PathEscape(segment))
+	}
+	return ub.containerURL + "/" + strings.Join(segments, "/")
+}
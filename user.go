@@ -2,7 +2,7 @@
 // information is NOT the same as storage account information. The associated JSON file is storied in the
 // %HOME%/.pit/account.json.
 
-package main
+package pit
 
 import (
 	"encoding/json"
@@ -26,17 +26,23 @@ type accountProperties struct {
 }
 
 type containerProperties struct {
-	Type    string // Example: "azure"
-	Account string // Example: "pithub"
-	Key     string // Example: "dGs3xAXFgM7bwJN9GtU0HaahFzqa77rWU/TWl8Oryqon93V28sexQ80V8V6PNedgEMhVu3C2eEBGcWUtFDcEUA=="
-	Name    string // Example: "nvm4zqwmtesttest"
-	URL     string // Example: "https://pithub.blob.core.windows.net/nvm4zqwmtesttest/"
-	Default string // Values:  "yes" or "no"
+	Type       string // Values:  "azure", "file", or "s3"
+	Account    string // Example: "pithub"
+	Key        string // Empty, see "pit credential set" (keys stored before the credential store existed are still used)
+	Name       string // Example: "nvm4zqwmtesttest"
+	URL        string // Example: "https://pithub.blob.core.windows.net/nvm4zqwmtesttest/"
+	Default    string // Values:  "yes" or "no"
+	Path       string // Example: "/Volumes/nas/pit" (only used by "file" containers)
+	Endpoint   string // Example: "http://127.0.0.1:10000/devstoreaccount1" (only used by "azure" and "s3" containers)
+	Region     string // Example: "us-west-2" (only used by "s3" containers)
+	Profile    string // Example: "production" (the name used with "pit profile use" and "--profile")
+	Production string // Values:  "yes" or "no"
 }
 
 type basicCollectionProperties struct {
 	NameLocal  string
 	NameRemote string
+	URL        string // Example: "https://pithub.blob.core.windows.net/nvm4zqwm" (the Container, empty for the default)
 }
 
 // Todo: Consider removing this field from accountProperties
@@ -47,6 +53,11 @@ const accountFileDescription = "Account and Container Properties"
 
 // User account methods.
 func (ap *accountProperties) userAppPath() string {
+	// The PIT_HOME environment variable overrides the location of the user app folder (e.g. for tests).
+	if pitHome := os.Getenv("PIT_HOME"); len(pitHome) != 0 {
+		return pitHome
+	}
+
 	user, _ := user.Current()
 	path := user.HomeDir + string(os.PathSeparator) + userAppFolderName
 	return path
@@ -87,8 +98,8 @@ func (ap *accountProperties) write() error {
 		return err
 	}
 
-	err = ioutil.WriteFile(ap.filePathAndName(), accountJSON, 0644) // The "0644" parameter is filemode.
-	return err
+	// The file is only readable by the user because it can hold keys that were stored before the credential store.
+	return writePrivateFile(ap.filePathAndName(), accountJSON)
 }
 
 func (ap *accountProperties) initialize() error {
@@ -134,70 +145,72 @@ func (ap *accountProperties) read() error {
 	return nil
 }
 
-func (ap *accountProperties) defaultContainer() (containerProperties, error) {
+// Returns the Container of the profile, or of the profile selected with PIT_PROFILE, otherwise the Container marked
+// as the default with "pit profile use", otherwise the first Container.
+func (ap *accountProperties) defaultContainer(profile string) (containerProperties, error) {
 	// Assumes verify() called previously.
+	var container containerProperties
 	err := ap.read()
 	if err != nil {
-		return ap.Containers[0], err
-	}
-
-	if ap.Containers == nil {
-		var container containerProperties
 		return container, err
 	}
 
-	return ap.Containers[0], nil
-}
-
-func (ap *accountProperties) defaultAccountProperties(environment string) {
-	// BugBug: Remove default account and key and email!
-	var container containerProperties
-	container.Type = "azure"
-	container.Account = "pithub"
-	container.Key = "2q62fVoYfT6ZOudTALzXBSz6eKOXh4CRgpMfuMWpyRFlUh/QB+K3IpaAm/hAUjrbMoZN9t0Cbl4lYHMU3lV89A=="
-	container.Name = "nvm4zqwmtesttest"
-	container.URL = "https://pithub.blob.core.windows.net/nvm4zqwmtesttest/"
-	container.Default = "yes"
-
-	if environment == "production" {
-		fmt.Println("Warning: **PRODUCTION** environment enabled!")
-		container.Name = "nvm4zqwm"
-	} else {
-		fmt.Println("**TEST** environment enabled.")
+	if name := profileSelection(profile); len(name) != 0 {
+		index := ap.profileIndex(name)
+		if index < 0 {
+			return container, fmt.Errorf("%w: profile \"%s\" (see \"pit profile list\")", ErrNotFound, name)
+		}
+		return ap.Containers[index], nil
 	}
 
-	ap.Description = accountFileDescription
-	ap.Email = "epogue@epogue.com"
-	ap.Containers = append(ap.Containers, container)
+	if ap.Containers == nil {
+		return container, err
+	}
 
-	err := ap.write()
-	if err != nil {
-		log.Println("Fatal Error: Unable to write account properties")
+	for _, container := range ap.Containers {
+		if container.Default == "yes" {
+			return container, nil
+		}
 	}
+	return ap.Containers[0], nil
 }
 
-func (ap *accountProperties) addBasicCollectionInfo(nameLocal string, nameRemote string) {
+func (ap *accountProperties) addBasicCollectionInfo(nameLocal string, nameRemote string, containerURL string) error {
 	var basicCollection basicCollectionProperties
 	basicCollection.NameLocal = nameLocal
 	basicCollection.NameRemote = nameRemote
+	basicCollection.URL = containerURL
 
 	err := ap.read()
-	check(err)
+	if err != nil {
+		return err
+	}
+
+	// A collection that is cloned again is only listed once.
+	for i, col := range ap.Collections {
+		if col.NameRemote == nameRemote {
+			ap.Collections[i] = basicCollection
+			return ap.write()
+		}
+	}
 
 	ap.Collections = append(ap.Collections, basicCollection)
-	ap.write()
+	return ap.write()
 }
 
-func (ap *accountProperties) getRemoteName(nameLocalIn string) (string, error) {
+// Returns the collection with the given local or remote name.
+func (ap *accountProperties) findCollection(name string) (basicCollectionProperties, bool, error) {
 	err := ap.read()
-	check(err)
+	if err != nil {
+		return basicCollectionProperties{}, false, err
+	}
 
-	collections := ap.Collections
-	for _, col := range collections {
-		if col.NameLocal == nameLocalIn {
-			return col.NameRemote, nil
+	for _, col := range ap.Collections {
+		if col.NameLocal == name || col.NameRemote == name {
+			return col, true, nil
 		}
 	}
 
-	return "", nil
+	var notFound basicCollectionProperties
+	return notFound, false, nil
 }
\ No newline at end of file
@@ -1,10 +1,9 @@
-package main
+package pit
 
 import (
 	"crypto/md5"
 	"fmt"
 	"io"
-	"log"
 	"math/rand"
 	"os"
 	"time"
@@ -86,45 +85,41 @@ func fileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-func md5File(fileName string) string {
+func md5File(fileName string) (string, error) {
 	f, err := os.Open(fileName)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	defer f.Close()
 
 	h := md5.New()
 	if _, err := io.Copy(h, f); err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
-func copyFile(sourceFileName string, destinationFileName string) bool {
-	var returnValue bool = true
+func copyFile(sourceFileName string, destinationFileName string) error {
 	sourceFile, err := os.Open(sourceFileName)
 	if err != nil {
-		returnValue = false
-		log.Fatal(err)
+		return err
 	}
 	defer sourceFile.Close()
 
 	// Create new file
 	newFile, err := os.Create(destinationFileName)
 	if err != nil {
-		returnValue = false
-		log.Fatal(err)
+		return err
 	}
-	defer newFile.Close()
 
 	_, err = io.Copy(newFile, sourceFile)
 	if err != nil {
-		returnValue = false
-		log.Fatal(err)
+		newFile.Close()
+		return err
 	}
 
-	return returnValue
+	return newFile.Close()
 }
 
 func deleteFile(fileName string) {
@@ -1,4 +1,4 @@
-package main
+package pit
 
 // The productVersion is intended to be updated whenever there is a potential new release. The numbering should be 
 // consistent with a major, minor, trivial changes terminology. Breaking changes should only be released with a major 